@@ -0,0 +1,122 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// acquireConnKey and acquirePoolKey are the statement instance keys the
+// acquired connection and the pool it was taken from are stored under.
+const (
+	acquireConnKey = "stratus:acquire_conn"
+	acquirePoolKey = "stratus:acquire_pool"
+)
+
+// AcquireHook receives the time a statement spent waiting for a pooled
+// connection, see `WithAcquireHook`.
+type AcquireHook func(ctx context.Context, waited time.Duration)
+
+// WithAcquireHook calls fn before every create, query, update, delete, row and
+// raw statement executed through GORM with how long it waited for a
+// connection from the pool, which grows once `WithMaxConnections` is reached.
+// Unlike `sql.DBStats.WaitDuration`, a process-wide total, this attributes
+// pool contention to the request that suffered it. To measure the wait, the
+// connection is acquired explicitly and held for the whole statement, which
+// adds a small overhead to every query. Statements ran inside a transaction
+// reuse its connection and aren't reported. Neither are statements ran with
+// `WithPrepareStmt`: GORM's prepared statement cache acquires the connections
+// itself, and its statements can't be moved onto an acquired one. fn runs
+// synchronously on the querying goroutine, hand anything slow off to another
+// one.
+func WithAcquireHook(fn AcquireHook) ConfigOption {
+	return func(c *config) error {
+		c.plugins = append(c.plugins, &acquireHookPlugin{hook: fn})
+		return nil
+	}
+}
+
+// acquireHookPlugin registers the GORM callbacks acquiring connections and
+// calling the acquire hook.
+type acquireHookPlugin struct {
+	hook AcquireHook
+}
+
+// Name implements `gorm.Plugin`.
+func (p *acquireHookPlugin) Name() string {
+	return "stratus:acquire_hook"
+}
+
+// Initialize implements `gorm.Plugin`.
+func (p *acquireHookPlugin) Initialize(db *gorm.DB) error {
+	// connections are acquired once read replicas are resolved, and for
+	// writes before GORM opens its default transaction, so that the
+	// transaction starts on the acquired connection.
+	return registerStatementCallbacks(db, "acquire_hook", statementCallbacks{
+		before:          p.before,
+		beforeStatement: true,
+		after:           p.after,
+		afterRow:        p.afterRow,
+	})
+}
+
+func (p *acquireHookPlugin) before(db *gorm.DB) {
+	sdb, ok := db.Statement.ConnPool.(*sql.DB)
+	if !ok || db.Error != nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	start := time.Now()
+	conn, err := sdb.Conn(ctx)
+	p.hook(ctx, time.Since(start))
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+
+	db.InstanceSet(acquireConnKey, conn)
+	db.InstanceSet(acquirePoolKey, sdb)
+	db.Statement.ConnPool = conn
+}
+
+// release restores the pool of the statement and returns its acquired
+// connection, if any.
+func (p *acquireHookPlugin) release(db *gorm.DB) *sql.Conn {
+	v, ok := db.InstanceGet(acquireConnKey)
+	if !ok {
+		return nil
+	}
+	conn, ok := v.(*sql.Conn)
+	if !ok {
+		return nil
+	}
+
+	if v, ok := db.InstanceGet(acquirePoolKey); ok {
+		if sdb, ok := v.(*sql.DB); ok {
+			db.Statement.ConnPool = sdb
+		}
+	}
+	db.InstanceSet(acquireConnKey, nil)
+
+	return conn
+}
+
+func (p *acquireHookPlugin) after(db *gorm.DB) {
+	if conn := p.release(db); conn != nil {
+		_ = conn.Close()
+	}
+}
+
+func (p *acquireHookPlugin) afterRow(db *gorm.DB) {
+	if conn := p.release(db); conn != nil {
+		// Close blocks until the rows are closed by the caller.
+		go conn.Close()
+	}
+}
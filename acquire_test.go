@@ -0,0 +1,72 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// waitIdle waits for every connection of sdb to be released, as connections
+// held for `Row` are released in the background once its rows are closed.
+func waitIdle(t *testing.T, sdb *sql.DB) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for sdb.Stats().InUse > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connection(s) still in use", sdb.Stats().InUse)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithAcquireHook(t *testing.T) {
+	calls := 0
+	// a single connection deadlocks any statement acquiring a second one
+	db := connectSQLite(t,
+		WithMaxConnections(1),
+		WithAcquireHook(func(context.Context, time.Duration) { calls++ }),
+	)
+	if err := db.AutoMigrate(&testUser{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	sdb, err := db.DB()
+	if err != nil {
+		t.Fatalf("DB() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"Create", func() error { return db.Create(&testUser{Name: "gopher"}).Error }},
+		{"Find", func() error {
+			var users []testUser
+			return db.Find(&users).Error
+		}},
+		{"Row", func() error {
+			var n int
+			return db.Model(&testUser{}).Select("count(*)").Row().Scan(&n)
+		}},
+		{"Rows", func() error {
+			rows, err := db.Model(&testUser{}).Rows()
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+			}
+			return rows.Close()
+		}},
+	}
+	for _, tt := range tests {
+		calls = 0
+		if err := tt.run(); err != nil {
+			t.Fatalf("%s error = %v", tt.name, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: hook called %d times, want once", tt.name, calls)
+		}
+		waitIdle(t, sdb)
+	}
+}
@@ -0,0 +1,133 @@
+package stratus
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	cloudsqlconn "github.com/funayman/cloud-sql-go-connector"
+	"github.com/funayman/cloud-sql-go-connector/postgres/pgxv5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	// defaultCloudSQLCredentialsFile is where deployed instances typically have
+	// the JSON key file for the Cloud SQL service account mounted.
+	defaultCloudSQLCredentialsFile = "/etc/sql/auth.json"
+
+	// cloudSQLDriverName prefixes the `database/sql` driver names the Cloud
+	// SQL connector is registered under.
+	cloudSQLDriverName = "cloudsql-postgres"
+)
+
+// cloudSQLDrivers counts the Cloud SQL connector registrations, to give each
+// one a unique driver name.
+var cloudSQLDrivers uint64
+
+// registerCloudSQL registers the Cloud SQL connector as a `database/sql`
+// driver so that DSNs can be opened through it. `database/sql` panics when a
+// driver name is registered twice, and keeps drivers registered for good, so
+// every connection registers its connector, with its own auth options, under
+// a unique name stored in `c.cloudSQLDriver`. Reconnecting the same
+// connection, be it through `Reconnect` or the health monitor, reuses its
+// connector instead of registering another one. Names already taken by drivers
+// registered outside of stratus are skipped, and should registration panic
+// all the same, the panic is returned as an error rather than crashing the
+// process. The connector is shut down by `c.release`.
+func registerCloudSQL(c *config) (err error) {
+	name := nextCloudSQLDriverName()
+
+	var authOption []cloudsqlconn.Option
+	if c.cloudSQLIAMAuth {
+		authOption = append(authOption, cloudsqlconn.WithIAMAuthN())
+	}
+	// check if the JSON key file exists, and use it for authentication if so.
+	// otherwise continue to use the application default credentials. this is
+	// typically only used for deployed instances and not for local development.
+	if _, err := os.Stat(c.cloudSQLCredentialsFile); err == nil {
+		authOption = append(authOption, cloudsqlconn.WithCredentialsFile(c.cloudSQLCredentialsFile))
+	}
+	if len(c.cloudSQLDialOptions) > 0 {
+		authOption = append(authOption, cloudsqlconn.WithDefaultDialOptions(c.cloudSQLDialOptions...))
+	}
+	if c.dialTimeout > 0 {
+		authOption = append(authOption, cloudsqlconn.WithDialFunc(timeoutDialFunc(proxy.Dial, c.dialTimeout)))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unable to register cloud sql driver %s: %v", name, r)
+		}
+	}()
+	cleanup, err := pgxv5.RegisterDriver(
+		name,
+		authOption...,
+	)
+	if err != nil {
+		return fmt.Errorf("pgxv5.RegisterDriver(...): %v", err)
+	}
+
+	c.cloudSQLDriver = name
+	c.cloudSQLCleanup = cleanup
+	return nil
+}
+
+// nextCloudSQLDriverName returns a Cloud SQL driver name that no driver has
+// been registered under yet.
+func nextCloudSQLDriverName() string {
+	registered := map[string]bool{}
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+
+	for {
+		name := fmt.Sprintf("%s-%d", cloudSQLDriverName, atomic.AddUint64(&cloudSQLDrivers, 1))
+		if !registered[name] {
+			return name
+		}
+	}
+}
+
+// isUnixSocketDSN reports whether the Postgres dsn points at a Unix socket,
+// such as the one exposed by the Cloud SQL Auth Proxy under `/cloudsql`.
+func isUnixSocketDSN(dsn string) bool {
+	cfg, err := pgconn.ParseConfig(dsn)
+	return err == nil && strings.HasPrefix(cfg.Host, "/")
+}
+
+// WithCloudSQLCredentialsFile overrides the location of the JSON key file
+// used to authenticate with Cloud SQL, which defaults to `/etc/sql/auth.json`.
+// As with the default location, the application default credentials are used
+// when no file exists at path. Only applies to the `cloudsql-postgres` driver.
+func WithCloudSQLCredentialsFile(path string) ConfigOption {
+	return func(c *config) error {
+		c.cloudSQLCredentialsFile = path
+		return nil
+	}
+}
+
+// WithIAMAuth toggles Cloud SQL IAM database authentication, which is enabled
+// by default. Disable it for instances using built-in Postgres users, in which
+// case the password has to be provided in the DSN. The credentials file is
+// still picked up either way, since the connector itself needs to
+// authenticate against the Cloud SQL Admin API regardless of how the database
+// user logs in. Only applies to the `cloudsql-postgres` driver.
+func WithIAMAuth(enabled bool) ConfigOption {
+	return func(c *config) error {
+		c.cloudSQLIAMAuth = enabled
+		return nil
+	}
+}
+
+// WithPrivateIP makes the Cloud SQL connector dial instances over their
+// private IP, which is required for instances only reachable from inside the
+// VPC. Only applies to the `cloudsql-postgres` driver.
+func WithPrivateIP() ConfigOption {
+	return func(c *config) error {
+		c.cloudSQLDialOptions = append(c.cloudSQLDialOptions, cloudsqlconn.WithPrivateIP())
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeCloudSQLCredentials writes a service account key file good enough for
+// the connector to be set up, which doesn't authenticate until dialing.
+func fakeCloudSQLCredentials(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.json")
+	key := `{
+		"type": "service_account",
+		"project_id": "stratus",
+		"private_key_id": "0",
+		"private_key": "",
+		"client_email": "stratus@stratus.iam.gserviceaccount.com",
+		"client_id": "0",
+		"token_uri": "https://oauth2.googleapis.com/token"
+	}`
+	if err := os.WriteFile(path, []byte(key), 0o600); err != nil {
+		t.Fatalf("unable to write credentials: %v", err)
+	}
+
+	return path
+}
+
+func TestRegisterCloudSQL(t *testing.T) {
+	creds := fakeCloudSQLCredentials(t)
+
+	names := map[string]bool{}
+	for _, iam := range []bool{true, false} {
+		c, err := newConfig([]Option{WithCloudSQLCredentialsFile(creds), WithIAMAuth(iam)})
+		if err != nil {
+			t.Fatalf("newConfig() error = %v", err)
+		}
+		if err := registerCloudSQL(c); err != nil {
+			t.Fatalf("registerCloudSQL() error = %v", err)
+		}
+		t.Cleanup(func() { _ = c.release() })
+
+		names[c.cloudSQLDriver] = true
+	}
+
+	if len(names) != 2 {
+		t.Fatalf("registered drivers %v, want 2 distinct names", names)
+	}
+	registered := map[string]bool{}
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+	for name := range names {
+		if !registered[name] {
+			t.Errorf("driver %s is not registered with database/sql", name)
+		}
+	}
+}
+
+func TestReopenCloudSQLReusesDriver(t *testing.T) {
+	opts := []Option{WithCloudSQLCredentialsFile(fakeCloudSQLCredentials(t)), WithPingOnConnect(false)}
+	dsn := "host=project:region:instance user=app dbname=app"
+
+	db, prev, err := open(context.Background(), nil, "cloudsql-postgres", dsn, opts...)
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = (&instance{db: db, c: prev}).close() })
+	drivers := len(sql.Drivers())
+
+	reopened, c, err := open(context.Background(), prev, "cloudsql-postgres", dsn, opts...)
+	if err != nil {
+		t.Fatalf("open() with prev error = %v", err)
+	}
+	t.Cleanup(func() { _ = closeDB(reopened) })
+
+	if c.cloudSQLDriver != prev.cloudSQLDriver {
+		t.Errorf("reopened with driver %s, want %s reused", c.cloudSQLDriver, prev.cloudSQLDriver)
+	}
+	if got := len(sql.Drivers()); got != drivers {
+		t.Errorf("%d drivers registered after reopening, want %d", got, drivers)
+	}
+}
+
+// nopDriver is a `database/sql` driver standing in for one registered outside
+// of stratus.
+type nopDriver struct{}
+
+func (nopDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+func TestConnectCloudSQLTwice(t *testing.T) {
+	t.Cleanup(Reset)
+	opts := []Option{WithCloudSQLCredentialsFile(fakeCloudSQLCredentials(t)), WithPingOnConnect(false)}
+	dsn := "host=project:region:instance user=app dbname=app"
+
+	// the name stratus would pick next is already taken
+	taken := fmt.Sprintf("%s-%d", cloudSQLDriverName, atomic.LoadUint64(&cloudSQLDrivers)+1)
+	sql.Register(taken, nopDriver{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := Connect("cloudsql-postgres", dsn, opts...); err != nil {
+			t.Fatalf("Connect() #%d error = %v", i+1, err)
+		}
+
+		mu.RLock()
+		name := instances[DefaultName].c.cloudSQLDriver
+		mu.RUnlock()
+		if name == taken {
+			t.Errorf("Connect() #%d registered under the taken name %s", i+1, taken)
+		}
+
+		if err := Close(); err != nil {
+			t.Fatalf("Close() #%d error = %v", i+1, err)
+		}
+	}
+}
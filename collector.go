@@ -0,0 +1,61 @@
+package stratus
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// statsCollector exports the `Stats()` of the primary database to Prometheus.
+type statsCollector struct {
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// NewStatsCollector returns a Prometheus collector exporting the connection
+// pool statistics of the primary database, as read by `Stats()` on every
+// scrape, without pulling in the GORM Prometheus plugin of `WithPrometheus`.
+// Metrics are named `<namespace>_db_*`, e.g. `myapp_db_open_connections`:
+// gauges for the maximum, open, in-use and idle connections, and counters for
+// the wait count and the total wait duration in seconds. Nothing is exported
+// while the database is not initialized. Register the collector with the
+// registry of your choice, e.g. `prometheus.MustRegister(...)`.
+func NewStatsCollector(namespace string) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+
+	return &statsCollector{
+		maxOpen:      desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:         desc("open_connections", "The number of established connections both in use and idle."),
+		inUse:        desc("in_use_connections", "The number of connections currently in use."),
+		idle:         desc("idle_connections", "The number of idle connections."),
+		waitCount:    desc("wait_count_total", "The total number of connections waited for."),
+		waitDuration: desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+	}
+}
+
+// Describe implements `prometheus.Collector`.
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+// Collect implements `prometheus.Collector`.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := Stats()
+	if err != nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
@@ -1,93 +1,576 @@
-// Package stratus is a lazy implementation of a singleton pattern for a single
-// database. The current implementation does not support multiple database.
-// Will cross that bridge when needed. For now, this is sufficient. It is
-// assumed that the database will be initialized from within `cmd/main.go` by
-// calling `stratus.Connect`, and that the `GetInstance()` function will be used to
-// load the reference into other services.
+// Package stratus is a lazy implementation of a singleton pattern for
+// databases. It is assumed that the primary database will be initialized from
+// within `cmd/main.go` by calling `stratus.Connect`, and that the
+// `GetInstance()` function will be used to load the reference into other
+// services. Additional databases, such as an analytics replica, can be
+// registered under a name with `stratus.ConnectNamed` and loaded with
+// `stratus.GetInstanceNamed`.
 package stratus
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	cloudsqlconn "github.com/funayman/cloud-sql-go-connector"
-	"github.com/funayman/cloud-sql-go-connector/postgres/pgxv5"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
+// DefaultName is the name the primary database is registered under by
+// `Connect`, and looked up by `GetInstance`.
+const DefaultName = "default"
+
 var (
-	db *gorm.DB
+	// mu guards instances, pending and the db of each instance, writes only
+	// happen during `ConnectNamed`, `CloseNamed`, `Reset` and when the health
+	// monitor reconnects.
+	mu        sync.RWMutex
+	instances = map[string]*instance{}
+	// pending holds the names being connected, each channel is closed once
+	// the connect attempt is over.
+	pending = map[string]chan struct{}{}
+
+	// ErrNotInitialized is returned when the database is accessed before it
+	// has been initialized by calling `stratus.Connect`. Use `errors.Is` to
+	// check for it.
+	ErrNotInitialized = errors.New("database not initialized")
+
+	// ErrAlreadyConnected is returned by `Connect` when the database has
+	// already been initialized by a previous call.
+	ErrAlreadyConnected = errors.New("database already connected")
+
+	// ErrUnsupportedDriver is returned, wrapped with the driver name, by
+	// `Connect` when the driver is not one of the supported ones. Use
+	// `errors.Is` to tell a typo in the driver name apart from a connection
+	// failure.
+	ErrUnsupportedDriver = errors.New("unsupported database")
 )
 
-// Connect opens the connection to the database through GORM. Will panic if a
-// connection fails, will return an error if issues arise when trying to set
-// DB options.
-func Connect(driver, dsn string, opts ...func(*sql.DB) error) (err error) {
-	cfg := &gorm.Config{Logger: logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold:             time.Second,  // Slow SQL threshold
-			LogLevel:                  logger.Error, // Log level
-			IgnoreRecordNotFoundError: true,         // Ignore ErrRecordNotFound error for logger
-			Colorful:                  false,        // Disable color
-		},
-	)}
+// instance is a database registered under a name, along with what is needed
+// to reopen it.
+type instance struct {
+	db      *gorm.DB
+	c       *config
+	open    opener
+	monitor *monitor
 
-	driver = strings.ToLower(driver)
-	switch driver {
-	case "cloudsql-postgres":
-		authFile := "/etc/sql/auth.json"
-		authOption := []cloudsqlconn.Option{cloudsqlconn.WithIAMAuthN()}
-		// check if default location for JSON key file exists, and use it for
-		// authentication if so. otherwise continue to use the application default
-		// credentials. this is typically only used for deployed instances and not
-		// for local development.
-		if _, err := os.Stat(authFile); err == nil {
-			authOption = append(authOption, cloudsqlconn.WithCredentialsFile(authFile))
-		}
-
-		_, err = pgxv5.RegisterDriver(
-			"cloudsql-postgres",
-			authOption...,
-		)
+	// connectedAt is when db was opened, i.e. the time of the last reconnect
+	// if it has been reopened by the health monitor.
+	connectedAt time.Time
+}
+
+// reopen opens a new connection for inst, registered under name, and swaps
+// it in, closing the old one. The new connection is discarded if inst has been
+// closed meanwhile.
+func (inst *instance) reopen(ctx context.Context, name string) error {
+	mu.RLock()
+	prev := inst.c
+	mu.RUnlock()
+
+	db, c, err := inst.open(ctx, prev)
+	if err != nil {
+		return err
+	}
+
+	// the new connection shares what was acquired along with the old one,
+	// such as the Cloud SQL connector, so only the databases are closed; the
+	// rest is released by the final `inst.close`.
+	mu.Lock()
+	if instances[name] != inst {
+		mu.Unlock()
+		c.unregisterMetrics()
+		_ = closeDB(db)
+		return ErrNotInitialized
+	}
+	old, oldConfig := inst.db, inst.c
+	inst.db, inst.c, inst.connectedAt = db, c, time.Now()
+	// under mu, so that concurrent reopens hand the metrics over in order
+	oldConfig.unregisterMetrics()
+	c.registerMetrics()
+	mu.Unlock()
+
+	_ = closeDB(old)
+	return nil
+}
+
+// close closes the database of inst, and releases what was acquired along
+// with it.
+func (inst *instance) close() error {
+	inst.c.unregisterMetrics()
+	err := closeDB(inst.db)
+	if rerr := inst.c.release(); err == nil {
+		err = rerr
+	}
+
+	return err
+}
+
+// opener opens a database, returning the configuration it was opened with.
+// prev is the configuration of the connection being replaced when reopening,
+// and nil otherwise.
+type opener func(ctx context.Context, prev *config) (*gorm.DB, *config, error)
+
+// Connect opens the connection to the database through GORM and sets it as
+// the package instance returned by `GetInstance()`. The opened `*gorm.DB` is
+// also returned for callers that prefer to hold their own reference. Will
+// return an error if the connection fails or if issues arise when trying to
+// set DB options.
+//
+// The supported drivers are `postgres` (or `postgresql`), `pgx`,
+// `cloudsql-postgres`, `mysql`, `sqlite` and `sqlserver`. `pgx` is plain
+// Postgres always opened from a pgx connection config, the same way the
+// Cloud SQL connector opens connections, so that local and deployed
+// environments behave alike. `cloudsql-postgres` dials the instance
+// named by the DSN host, e.g. `host=project:region:instance`, through the
+// Cloud SQL connector, which is how deployed services are expected to
+// connect. For local development against the Cloud SQL Auth Proxy, point the
+// DSN host at the socket the proxy exposes instead, e.g.
+// `host=/cloudsql/project:region:instance`; such DSNs are dialed directly as
+// plain Postgres, even with the `cloudsql-postgres` driver, so the same
+// driver name can be used in both environments. IAM authentication is then up
+// to the proxy, e.g. by running it with `--auto-iam-authn`.
+//
+// Postgres DSNs may carry the pgx pool parameters `pool_max_conns`,
+// `pool_max_conn_lifetime` and `pool_max_conn_idle_time`, which are applied
+// to the pool before the DBOptions, so that options such as
+// `WithMaxConnections` take precedence over them. Other pool parameters have
+// no `database/sql` equivalent and make Connect fail.
+//
+// Connect is safe to call from multiple goroutines; the first caller wins and
+// subsequent callers receive the existing instance along with
+// `ErrAlreadyConnected` until `Close` is called.
+func Connect(driver, dsn string, opts ...Option) (*gorm.DB, error) {
+	return ConnectContext(context.Background(), driver, dsn, opts...)
+}
+
+// MustConnect behaves like `Connect`, but panics if the connection cannot be
+// established instead of returning an error. Intended to be called from
+// `cmd/main.go`, where there is nothing better to do with the error anyway.
+func MustConnect(driver, dsn string, opts ...Option) *gorm.DB {
+	db, err := Connect(driver, dsn, opts...)
+	if err != nil {
+		panic(fmt.Errorf("unable to connect to database: %w", err))
+	}
+
+	return db
+}
+
+// ConnectOnce behaves like `Connect`, but returns the existing instance
+// without an error when the database has already been initialized, for
+// applications where several independent init functions may set up the
+// database. Only the first successful call opens a connection, later ones
+// return right away without opening anything; a failed call leaves the
+// database uninitialized, so that a later call can try again. The opts of
+// later calls are ignored.
+func ConnectOnce(driver, dsn string, opts ...Option) (*gorm.DB, error) {
+	db, err := Connect(driver, dsn, opts...)
+	if errors.Is(err, ErrAlreadyConnected) {
+		return db, nil
+	}
+
+	return db, err
+}
+
+// ConnectContext behaves like `Connect`, but gives up once ctx is done. The
+// context deadline is honored when dialing and pinging the database, making
+// it suitable for bounding service startup.
+func ConnectContext(ctx context.Context, driver, dsn string, opts ...Option) (*gorm.DB, error) {
+	return connect(ctx, DefaultName, func(ctx context.Context, prev *config) (*gorm.DB, *config, error) {
+		return open(ctx, prev, driver, dsn, opts...)
+	})
+}
+
+// ConnectNamed behaves like `Connect`, but registers the database under the
+// given name so that it can be loaded with `GetInstanceNamed(name)`. Each name
+// can only be connected once until it is closed.
+func ConnectNamed(name, driver, dsn string, opts ...Option) (*gorm.DB, error) {
+	return connect(context.Background(), name, func(ctx context.Context, prev *config) (*gorm.DB, *config, error) {
+		return open(ctx, prev, driver, dsn, opts...)
+	})
+}
+
+// ConnectWithDB behaves like `Connect`, but opens GORM on top of an already
+// established `*sql.DB` rather than a DSN, giving full control over the
+// underlying connection, e.g. one wrapping a test container or a mock. The
+// driver only selects the GORM dialect. The connection is owned by stratus
+// from then on, and is closed by `Close`.
+func ConnectWithDB(driver string, sdb *sql.DB, opts ...Option) (*gorm.DB, error) {
+	return connect(context.Background(), DefaultName, func(ctx context.Context, _ *config) (*gorm.DB, *config, error) {
+		c, err := newConfig(opts)
 		if err != nil {
-			return fmt.Errorf("pgxv5.RegisterDriver(...): %v", err)
+			return nil, nil, phaseError(PhaseOptions, err)
 		}
+		// sdb belongs to the caller, and can't be reopened by stratus
+		c.external = true
 
-		sdb, err := sql.Open(driver, dsn)
+		driver = strings.ToLower(driver)
+		c.driver = driver
+		dialector, err := c.connDialector(driver, sdb)
 		if err != nil {
-			return fmt.Errorf("sql.Open(...): %v", err)
+			return nil, nil, phaseError(PhaseOptions, err)
+		}
+
+		db, err := c.open(ctx, driver, "", dialector)
+		return db, c, err
+	})
+}
+
+// connect registers the database returned by open under name, unless a
+// database is already registered under that name. mu is only held to reserve
+// and publish name, so that opening a slow database doesn't block access to
+// the ones already connected. Concurrent callers for the same name wait for
+// the one opening it, and only try themselves if it failed.
+func connect(ctx context.Context, name string, open opener) (*gorm.DB, error) {
+	for {
+		mu.Lock()
+		if inst, ok := instances[name]; ok {
+			mu.Unlock()
+			return inst.db, ErrAlreadyConnected
+		}
+		p, ok := pending[name]
+		if !ok {
+			break
+		}
+		mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("unable to open db: %w", ctx.Err())
+		case <-p:
+		}
+	}
+	p := make(chan struct{})
+	pending[name] = p
+	mu.Unlock()
+
+	db, c, err := openContext(ctx, open)
+
+	mu.Lock()
+	defer mu.Unlock()
+	delete(pending, name)
+	close(p)
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &instance{db: db, c: c, connectedAt: time.Now()}
+	if !c.external {
+		inst.open = open
+	}
+	if c.healthInterval > 0 {
+		inst.monitor = startMonitor(name, inst, c.healthInterval)
+	}
+	instances[name] = inst
+	return db, nil
+}
+
+// openContext calls open, but returns as soon as ctx is done. Not every
+// driver accepts a context while opening (e.g. the version query ran by the
+// mysql dialector), so open runs in the background, and a connection that is
+// opened after we gave up on it is closed right away.
+func openContext(ctx context.Context, open opener) (*gorm.DB, *config, error) {
+	type result struct {
+		db  *gorm.DB
+		c   *config
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		db, c, err := open(ctx, nil)
+		ch <- result{db, c, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.err == nil {
+				_ = (&instance{db: r.db, c: r.c}).close()
+			}
+		}()
+		return nil, nil, fmt.Errorf("unable to open db: %w", ctx.Err())
+	case r := <-ch:
+		return r.db, r.c, r.err
+	}
+}
+
+// open does the heavy lifting for `connect`, opening and verifying the
+// connection to dsn without touching the package instances. prev is the
+// configuration of the connection being replaced, if any. Any password in dsn
+// is scrubbed from the returned error.
+func open(ctx context.Context, prev *config, driver, dsn string, opts ...Option) (_ *gorm.DB, _ *config, err error) {
+	defer func() { err = redactError(err) }()
+
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, nil, phaseError(PhaseOptions, err)
+	}
+
+	driver = strings.ToLower(driver)
+	c.driver = driver
+	if driver == "cloudsql-postgres" && isUnixSocketDSN(dsn) {
+		// the Cloud SQL Auth Proxy already took care of the connector's job
+		driver = "postgres"
+	}
+	if isPostgres(driver) {
+		var poolOpts []DBOption
+		if dsn, poolOpts, err = poolParams(dsn); err != nil {
+			return nil, nil, phaseError(PhaseOptions, err)
+		}
+		c.dbOpts = append(poolOpts, c.dbOpts...)
+	}
+	switch {
+	case driver == "cloudsql-postgres" && prev != nil && prev.cloudSQLDriver != "":
+		// drivers can't be unregistered, so rather than registering one more
+		// for every reconnect, keep using the connector of prev, which was set
+		// up from the same options.
+		c.cloudSQLDriver, c.cloudSQLCleanup = prev.cloudSQLDriver, prev.cloudSQLCleanup
+	case driver == "cloudsql-postgres":
+		if err := registerCloudSQL(c); err != nil {
+			return nil, nil, phaseError(PhaseRegisterDriver, err)
+		}
+		defer func() {
+			if err != nil {
+				_ = c.release()
+			}
+		}()
+	}
+
+	dialector, err := c.dialector(driver, dsn)
+	if err != nil {
+		return nil, nil, phaseError(PhaseOptions, err)
+	}
+
+	db, err := c.open(ctx, driver, dsn, dialector)
+	return db, c, err
+}
+
+// applyDBOptions applies the DB options of c to sdb in order, recording the
+// idle connection limit they set along the way.
+func (c *config) applyDBOptions(sdb *sql.DB) error {
+	idleConnMu.Lock()
+	idleConnRecorders[sdb] = c
+	idleConnMu.Unlock()
+	defer func() {
+		idleConnMu.Lock()
+		delete(idleConnRecorders, sdb)
+		idleConnMu.Unlock()
+	}()
+
+	for _, opt := range c.dbOpts {
+		if err := opt(sdb); err != nil {
+			return fmt.Errorf("db opts failure: %w", err)
 		}
+	}
+
+	return nil
+}
 
-		db, err = gorm.Open(postgres.New(postgres.Config{Conn: sdb}), cfg)
+// open opens GORM with dialector, then applies the DB options and verifies
+// the connection. dsn is only used for logging, and is empty for connections
+// opened by the caller.
+func (c *config) open(ctx context.Context, driver, dsn string, dialector gorm.Dialector) (_ *gorm.DB, err error) {
+	gdb, err := gorm.Open(dialector, c.gormConfig())
+	if err != nil {
+		return nil, phaseError(PhaseGormOpen, fmt.Errorf("unable to open db: %w", err))
+	}
+	defer func() {
 		if err != nil {
-			return fmt.Errorf("unable to open db: %w", err)
+			c.unregisterMetrics()
 		}
-	case "postgresql", "postgres":
-		db, err = gorm.Open(postgres.Open(dsn), cfg)
+	}()
+	for _, plugin := range c.plugins {
+		if err := gdb.Use(plugin); err != nil {
+			_ = closeDB(gdb)
+			return nil, phaseError(PhaseOptions, fmt.Errorf("unable to register plugin %s: %w", plugin.Name(), err))
+		}
+	}
+
+	// support db options
+	sdb, err := gdb.DB()
+	if err != nil {
+		return nil, phaseError(PhaseGormOpen, fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err))
+	}
+	if !c.external {
+		// the limit of a `*sql.DB` no option changed yet
+		c.maxIdleConns, c.maxIdleConnsKnown = defaultMaxIdleConns, true
+	}
+	if err := c.applyDBOptions(sdb); err != nil {
+		_ = sdb.Close()
+		return nil, phaseError(PhaseOptions, err)
+	}
+
+	c.logStartup(ctx, dsn, gdb, sdb)
+
+	// most drivers open connections lazily, so make sure the database is
+	// actually reachable rather than finding out on the first query.
+	if c.pingOnConnect {
+		if err := sdb.PingContext(ctx); err != nil {
+			_ = sdb.Close()
+			return nil, phaseError(PhasePing, fmt.Errorf("unable to ping db: %w", err))
+		}
+	}
+
+	if len(c.replicas) > 0 {
+		if err := useReplicas(ctx, gdb, driver, c); err != nil {
+			_ = closeDB(gdb)
+			return nil, err
+		}
+	}
+
+	return gdb, nil
+}
+
+// dialector returns the GORM dialector opening dsn with the given driver.
+// Errors are those of the options and the DSN, unless tagged with their
+// phase.
+func (c *config) dialector(driver, dsn string) (gorm.Dialector, error) {
+	if c.readOnly && !isPostgres(driver) {
+		return nil, fmt.Errorf("read-only mode is not supported by %s", driver)
+	}
+
+	switch driver {
+	case "cloudsql-postgres":
+		dsn = c.postgresDSN(dsn)
+		// the connector parses the DSN itself, so the driver settings have to
+		// be passed along through it rather than through `postgres.Config`.
+		if c.simpleProtocol {
+			dsn = setDSNParam(dsn, "default_query_exec_mode", "simple_protocol")
+		}
+		sdb, err := c.openInitSQL(c.cloudSQLDriver, dsn)
 		if err != nil {
-			return fmt.Errorf("unable to open db: %w", err)
+			return nil, phaseError(PhaseOpen, fmt.Errorf("sql.Open(...): %v", err))
 		}
+		return postgres.New(postgres.Config{Conn: sdb}), nil
+	case "pgx":
+		return c.pgxDialector(c.postgresDSN(dsn))
+	case "postgresql", "postgres":
+		dsn = c.postgresDSN(dsn)
+		if c.usesPgxConfig() {
+			return c.pgxDialector(dsn)
+		}
+		return postgres.New(postgres.Config{
+			DSN:                  dsn,
+			PreferSimpleProtocol: c.simpleProtocol,
+		}), nil
+	case "mysql":
+		return mysql.New(mysql.Config{
+			DSN:               dsn,
+			DefaultStringSize: c.defaultStringSize,
+		}), nil
+	case "sqlite":
+		// dsn can either be a file path or `:memory:` for an in-memory database,
+		// which is handy for local development and tests.
+		return sqlite.Open(dsn), nil
+	case "sqlserver":
+		return sqlserver.New(sqlserver.Config{
+			DSN:               dsn,
+			DefaultStringSize: int(c.defaultStringSize),
+		}), nil
 	default:
-		return errors.New("unsupported database: " + driver)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driver)
+	}
+}
+
+// isPostgres reports whether driver is one of the Postgres drivers.
+func isPostgres(driver string) bool {
+	switch driver {
+	case "cloudsql-postgres", "pgx", "postgresql", "postgres":
+		return true
+	default:
+		return false
+	}
+}
+
+// postgresDSN sets the server parameters configured through options on a
+// Postgres DSN, pgx sends unknown DSN parameters to the server when the
+// connection starts up.
+func (c *config) postgresDSN(dsn string) string {
+	keys := make([]string, 0, len(c.connParams))
+	for k := range c.connParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if c.timeZone != "" && strings.EqualFold(k, "TimeZone") {
+			continue
+		}
+		if c.applicationName != "" && strings.EqualFold(k, "application_name") {
+			continue
+		}
+		dsn = setDSNParam(dsn, k, c.connParams[k])
+	}
+
+	if c.applicationName != "" {
+		dsn = setDSNParam(dsn, "application_name", c.applicationName)
+	} else if !hasDSNParam(dsn, "application_name") {
+		if exe, err := os.Executable(); err == nil {
+			dsn = setDSNParam(dsn, "application_name", filepath.Base(exe))
+		}
+	}
+
+	if c.statementTimeout > 0 {
+		dsn = setDSNParam(dsn, "statement_timeout", strconv.FormatInt(c.statementTimeout.Milliseconds(), 10))
+	}
+	if c.timeZone != "" {
+		dsn = setDSNParam(dsn, "TimeZone", c.timeZone)
+	}
+	if c.readOnly {
+		dsn = setDSNParam(dsn, "default_transaction_read_only", "on")
+	}
+
+	return dsn
+}
+
+// connDialector returns the GORM dialector for the given driver on top of an
+// existing connection.
+func (c *config) connDialector(driver string, sdb *sql.DB) (gorm.Dialector, error) {
+	if c.readOnly {
+		return nil, errors.New("read-only mode is not supported on an existing connection")
+	}
+
+	switch driver {
+	case "cloudsql-postgres", "postgresql", "postgres", "pgx":
+		return postgres.New(postgres.Config{Conn: sdb}), nil
+	case "mysql":
+		return mysql.New(mysql.Config{
+			Conn:              sdb,
+			DefaultStringSize: c.defaultStringSize,
+		}), nil
+	case "sqlite":
+		return &sqlite.Dialector{Conn: sdb}, nil
+	case "sqlserver":
+		return sqlserver.New(sqlserver.Config{
+			Conn:              sdb,
+			DefaultStringSize: int(c.defaultStringSize),
+		}), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driver)
+	}
+}
+
+// closeDB closes the `*sql.DB` underneath db, along with any replicas.
+func closeDB(db *gorm.DB) error {
+	if err := closeReplicas(db); err != nil {
+		return err
 	}
 
-	// support db options
 	sdb, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err)
 	}
-	for _, opt := range opts {
-		if err := opt(sdb); err != nil {
-			return fmt.Errorf("db opts failure: %w", err)
-		}
+	if err := sdb.Close(); err != nil {
+		return fmt.Errorf("unable to close db: %w", err)
 	}
 
 	return nil
@@ -97,27 +580,245 @@ func Connect(driver, dsn string, opts ...func(*sql.DB) error) (err error) {
 // instance. GetInstance will panic if the database has not been initialized by
 // calling `db.Connect`.
 func GetInstance() *gorm.DB {
-	if db == nil {
+	return GetInstanceNamed(DefaultName)
+}
+
+// TryGetInstance behaves like `GetInstance`, but returns `ErrNotInitialized`
+// instead of panicking if the database has not been initialized, so that
+// callers which may run before initialization completes can degrade
+// gracefully.
+func TryGetInstance() (*gorm.DB, error) {
+	return lookup(DefaultName)
+}
+
+// IsInitialized reports whether the primary database has been initialized,
+// without panicking. Useful in shutdown handlers and optional code paths that
+// should no-op when `stratus.Connect` was never called.
+func IsInitialized() bool {
+	_, err := lookup(DefaultName)
+	return err == nil
+}
+
+// Driver returns the normalized, i.e. lowercased, driver name the primary
+// database was connected with, such as `postgres` or `cloudsql-postgres`, or
+// an empty string if the database has not been initialized.
+func Driver() string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	inst, ok := instances[DefaultName]
+	if !ok {
+		return ""
+	}
+
+	return inst.c.driver
+}
+
+// ConnectedAt returns when the primary database was connected, or last
+// reconnected by the health monitor, see `WithHealthMonitor`. The boolean
+// reports whether the database has been initialized.
+func ConnectedAt() (time.Time, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	inst, ok := instances[DefaultName]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return inst.connectedAt, true
+}
+
+// Uptime returns how long the primary database has been connected since
+// `ConnectedAt`, or zero if it has not been initialized.
+func Uptime() time.Duration {
+	at, ok := ConnectedAt()
+	if !ok {
+		return 0
+	}
+
+	return time.Since(at)
+}
+
+// GetInstanceNamed returns the database registered under name by
+// `ConnectNamed`. GetInstanceNamed will panic if no database has been
+// initialized under that name.
+func GetInstanceNamed(name string) *gorm.DB {
+	db, err := lookup(name)
+	if err != nil {
 		panic("database accessed before initialized")
 	}
 
 	return db
 }
 
-// WithMaxConnections allows for the setting of `MaxOpenConns` for the
-// underlying `*sql.DB` instance during database initialization.
-func WithMaxConnections(max int) func(*sql.DB) error {
-	return func(db *sql.DB) error {
-		db.SetMaxOpenConns(max)
-		return nil
+// Close closes the underlying `*sql.DB` and resets the package instance so
+// that subsequent calls to `GetInstance()` panic rather than hand out a closed
+// connection. Returns `ErrNotInitialized` if `stratus.Connect` was never
+// called.
+func Close() error {
+	return CloseNamed(DefaultName)
+}
+
+// CloseNamed behaves like `Close` for the database registered under name.
+func CloseNamed(name string) error {
+	mu.Lock()
+	inst, ok := instances[name]
+	delete(instances, name)
+	mu.Unlock()
+
+	if !ok {
+		return ErrNotInitialized
 	}
+
+	// the monitor may be waiting on mu to swap in a new connection, so it can
+	// only be stopped once mu has been released.
+	inst.monitor.Stop()
+	return inst.close()
 }
 
-// WithMaxIdleConnections allows for the setting of `MaxIdleConns` for the
-// underlying `*sql.DB` instance during database initialization.
-func WithMaxIdleConnections(max int) func(*sql.DB) error {
-	return func(db *sql.DB) error {
-		db.SetMaxIdleConns(max)
-		return nil
+// Reconnect replaces the pool of the primary database with a fresh one, by
+// re-running the original connect with the same driver, DSN and options, e.g.
+// after a planned Cloud SQL failover left the pooled connections pointing at
+// the old primary. The new connection is opened and verified first, then
+// swapped in atomically, so `GetInstance()` never hands out a closed
+// connection; if it can't be opened, the current one is kept and the error is
+// returned. Calls are safe to make concurrently, the last one to finish wins.
+//
+// The old pool is closed right after the swap. Queries already running on it
+// are allowed to finish, but during a brief window, queries started through a
+// `*gorm.DB` fetched before the swap fail with "sql: database is closed", so
+// fetch it with `GetInstance()` when needed rather than caching it. Returns
+// `ErrNotInitialized` if `stratus.Connect` was never called, and an error for
+// connections made with `ConnectWithDB`, which stratus can't reopen.
+func Reconnect(ctx context.Context) error {
+	mu.RLock()
+	inst, ok := instances[DefaultName]
+	mu.RUnlock()
+	if !ok {
+		return ErrNotInitialized
 	}
+	if inst.open == nil {
+		return errors.New("unable to reconnect, the connection was opened by the caller")
+	}
+
+	if err := inst.reopen(ctx, DefaultName); err != nil {
+		return fmt.Errorf("unable to reconnect: %w", err)
+	}
+
+	return nil
+}
+
+// drainPollInterval is how often `Drain` checks for connections still in use.
+const drainPollInterval = 50 * time.Millisecond
+
+// Drain closes the primary database gracefully, for use in SIGTERM handlers.
+// The database is unregistered right away, as with `Close`, and its pool is
+// shrunk to a single connection, which is as close to handing out no new
+// connections as `database/sql` allows. Drain then waits until no connection
+// is in use anymore, or until ctx is done, before closing the pool. The
+// health monitor, if any, is stopped before waiting so that it doesn't
+// reconnect or ping while the pool drains. The pool is closed in every case,
+// and ctx's error is returned if the connections did not drain in time.
+func Drain(ctx context.Context) error {
+	mu.Lock()
+	inst, ok := instances[DefaultName]
+	delete(instances, DefaultName)
+	mu.Unlock()
+
+	if !ok {
+		return ErrNotInitialized
+	}
+	inst.monitor.Stop()
+
+	sdb, err := inst.db.DB()
+	if err != nil {
+		_ = inst.close()
+		return fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err)
+	}
+	// zero would lift the limit altogether
+	sdb.SetMaxOpenConns(1)
+	sdb.SetMaxIdleConns(0)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for sdb.Stats().InUse > 0 {
+		select {
+		case <-ctx.Done():
+			_ = inst.close()
+			return fmt.Errorf("unable to drain db: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return inst.close()
+}
+
+// Reset closes every open connection, if any, and clears the package
+// instances so the next call to `Connect` starts clean. Unlike `Close`, Reset
+// does not care whether the database was ever initialized and ignores errors
+// from closing the connection. It is intended for test teardown, e.g.
+// `t.Cleanup(stratus.Reset)`, and should not be used in application code.
+func Reset() {
+	mu.Lock()
+	closing := instances
+	instances = map[string]*instance{}
+	mu.Unlock()
+
+	for _, inst := range closing {
+		inst.monitor.Stop()
+		_ = inst.close()
+	}
+}
+
+// Ping verifies that the database is reachable by pinging the underlying
+// `*sql.DB` with the given context. Intended to be used by liveness and
+// readiness probes. Returns `ErrNotInitialized` if `stratus.Connect` was never
+// called.
+func Ping(ctx context.Context) error {
+	sdb, err := sqlDB(DefaultName)
+	if err != nil {
+		return err
+	}
+	if err := sdb.PingContext(ctx); err != nil {
+		return fmt.Errorf("unable to ping db: %w", err)
+	}
+
+	return nil
+}
+
+// SQLDB returns the `*sql.DB` underneath the primary database, for tooling
+// built on `database/sql` rather than GORM, such as golang-migrate. The pool
+// is still owned by stratus, close it with `Close` rather than directly.
+// Returns `ErrNotInitialized` if `stratus.Connect` was never called.
+func SQLDB() (*sql.DB, error) {
+	return sqlDB(DefaultName)
+}
+
+// lookup fetches the database registered under name without panicking.
+func lookup(name string) (*gorm.DB, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	inst, ok := instances[name]
+	if !ok {
+		return nil, ErrNotInitialized
+	}
+
+	return inst.db, nil
+}
+
+// sqlDB fetches the `*sql.DB` underneath the database registered under name.
+func sqlDB(name string) (*sql.DB, error) {
+	gdb, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb, err := gdb.DB()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err)
+	}
+
+	return sdb, nil
 }
@@ -0,0 +1,68 @@
+package stratus
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Environment variables read by `ConnectFromEnv`.
+const (
+	EnvDriver          = "STRATUS_DRIVER"
+	EnvDSN             = "STRATUS_DSN"
+	EnvMaxOpenConns    = "STRATUS_MAX_OPEN_CONNS"
+	EnvMaxIdleConns    = "STRATUS_MAX_IDLE_CONNS"
+	EnvConnMaxLifetime = "STRATUS_CONN_MAX_LIFETIME"
+)
+
+// ConnectFromEnv calls `Connect` with the driver, DSN and pool settings read
+// from the environment:
+//
+//   - STRATUS_DRIVER, defaults to `postgres`
+//   - STRATUS_DSN, required
+//   - STRATUS_MAX_OPEN_CONNS, an integer passed to `WithMaxConnections`
+//   - STRATUS_MAX_IDLE_CONNS, an integer passed to `WithMaxIdleConnections`
+//   - STRATUS_CONN_MAX_LIFETIME, a duration such as `30m` passed to
+//     `WithConnMaxLifetime`
+//
+// Pool settings that are not set are left to the driver defaults. Any opts are
+// applied after the ones built from the environment, and win over them.
+func ConnectFromEnv(opts ...Option) (*gorm.DB, error) {
+	driver := os.Getenv(EnvDriver)
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	dsn := os.Getenv(EnvDSN)
+	if dsn == "" {
+		return nil, fmt.Errorf("%s is not set", EnvDSN)
+	}
+
+	var envOpts []Option
+	if v := os.Getenv(EnvMaxOpenConns); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", EnvMaxOpenConns, v, err)
+		}
+		envOpts = append(envOpts, WithMaxConnections(n))
+	}
+	if v := os.Getenv(EnvMaxIdleConns); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", EnvMaxIdleConns, v, err)
+		}
+		envOpts = append(envOpts, WithMaxIdleConnections(n))
+	}
+	if v := os.Getenv(EnvConnMaxLifetime); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", EnvConnMaxLifetime, v, err)
+		}
+		envOpts = append(envOpts, WithConnMaxLifetime(d))
+	}
+
+	return Connect(driver, dsn, append(envOpts, opts...)...)
+}
@@ -0,0 +1,140 @@
+package stratus
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ConnectPhase identifies the step of opening a connection that failed, see
+// `ConnectError`.
+type ConnectPhase string
+
+// Phases of opening a connection, in the order they happen.
+const (
+	// PhaseOptions covers validating and applying the options, including
+	// DBOptions and plugins, and parsing the DSN.
+	PhaseOptions ConnectPhase = "options"
+	// PhaseRegisterDriver covers registering the Cloud SQL connector.
+	PhaseRegisterDriver ConnectPhase = "register driver"
+	// PhaseOpen covers opening the `*sql.DB` of drivers opened outside of
+	// GORM, such as `cloudsql-postgres`.
+	PhaseOpen ConnectPhase = "open"
+	// PhaseGormOpen covers `gorm.Open`, which opens the `*sql.DB` of the
+	// other drivers, and registering the replicas.
+	PhaseGormOpen ConnectPhase = "gorm open"
+	// PhasePing covers pinging the database and its replicas.
+	PhasePing ConnectPhase = "ping"
+)
+
+// ConnectError is returned by `Connect` and its variants when opening the
+// connection fails, telling which phase failed, e.g. to retry on `PhaseOpen`
+// and `PhasePing` failures while giving up on `PhaseOptions` ones. Use
+// `errors.As` to get hold of it; the cause still unwraps as before. An
+// unsupported driver is reported as `ErrUnsupportedDriver` rather than a
+// ConnectError.
+type ConnectError struct {
+	Phase ConnectPhase
+	Err   error
+}
+
+// Error implements `error`, the message is the one of the cause.
+func (e *ConnectError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause of the failure.
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// phaseError wraps err in a `ConnectError` for phase, unless err is nil, an
+// unsupported driver or already identifies its phase.
+func phaseError(phase ConnectPhase, err error) error {
+	var cerr *ConnectError
+	if err == nil || errors.Is(err, ErrUnsupportedDriver) || errors.As(err, &cerr) {
+		return err
+	}
+
+	return &ConnectError{Phase: phase, Err: err}
+}
+
+// Kinds of database errors classified by `AsDBError`, use `errors.Is` on the
+// returned `DBError` to check for them.
+var (
+	ErrUniqueViolation     = errors.New("unique violation")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrNotNullViolation    = errors.New("not null violation")
+	ErrCheckViolation      = errors.New("check violation")
+)
+
+// pgErrorKinds maps the Postgres error codes classified by `AsDBError` to
+// their kind.
+var pgErrorKinds = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23502": ErrNotNullViolation,
+	"23514": ErrCheckViolation,
+}
+
+// DBError is a Postgres error classified by `AsDBError`.
+type DBError struct {
+	// Kind is one of `ErrUniqueViolation`, `ErrForeignKeyViolation`,
+	// `ErrNotNullViolation` or `ErrCheckViolation`.
+	Kind error
+	// Code is the Postgres error code, e.g. `23505`.
+	Code string
+	// Table, Column and Constraint name the violated constraint, as far as
+	// Postgres reports them.
+	Table      string
+	Column     string
+	Constraint string
+	// Err is the underlying pgx error.
+	Err error
+}
+
+// Error implements `error`, the message is the one of the pgx error.
+func (e *DBError) Error() string {
+	return e.Err.Error()
+}
+
+// Is reports whether target is the kind of e.
+func (e *DBError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying pgx error.
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// AsDBError classifies the constraint violation reported by Postgres in err,
+// such as a duplicate key, so that handlers can map it to a response, e.g. a
+// 409 for `ErrUniqueViolation`:
+//
+//	if dberr, ok := stratus.AsDBError(err); ok && errors.Is(dberr, stratus.ErrUniqueViolation) {
+//		...
+//	}
+//
+// Reports false if err is not a Postgres error, or not one of the classified
+// kinds. Only errors coming from the Postgres drivers can be classified.
+func AsDBError(err error) (*DBError, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	kind, ok := pgErrorKinds[pgErr.Code]
+	if !ok {
+		return nil, false
+	}
+
+	return &DBError{
+		Kind:       kind,
+		Code:       pgErr.Code,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		Constraint: pgErr.ConstraintName,
+		Err:        pgErr,
+	}, true
+}
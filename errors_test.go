@@ -0,0 +1,40 @@
+package stratus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectErrorPhase(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect func() error
+		want    ConnectPhase
+	}{
+		{"read-only sqlite", func() error {
+			_, err := Connect("sqlite", ":memory:", WithReadOnly())
+			return err
+		}, PhaseOptions},
+		{"unparseable pgx dsn", func() error {
+			_, err := Connect("pgx", "host=db port=notaport")
+			return err
+		}, PhaseOptions},
+		{"read-only existing connection", func() error {
+			_, err := ConnectWithDB("sqlite", openSQLite(t), WithReadOnly())
+			return err
+		}, PhaseOptions},
+	}
+	for _, tt := range tests {
+		err := tt.connect()
+		Reset()
+
+		var cerr *ConnectError
+		if !errors.As(err, &cerr) {
+			t.Errorf("%s: error = %v, want a *ConnectError", tt.name, err)
+			continue
+		}
+		if cerr.Phase != tt.want {
+			t.Errorf("%s: Phase = %q, want %q", tt.name, cerr.Phase, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package stratus
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// ConnectFromFile calls `Connect` with the DSN read from dsnPath, such as a
+// Kubernetes Secret mounted as a file, which keeps the DSN out of the
+// environment and process listings. Trailing whitespace, including the final
+// newline most editors add, is trimmed from the file contents.
+func ConnectFromFile(driver, dsnPath string, opts ...Option) (*gorm.DB, error) {
+	b, err := os.ReadFile(dsnPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dsn file: %w", err)
+	}
+
+	dsn := strings.TrimRightFunc(string(b), unicode.IsSpace)
+	if dsn == "" {
+		return nil, fmt.Errorf("dsn file %s is empty", dsnPath)
+	}
+
+	return Connect(driver, dsn, opts...)
+}
@@ -0,0 +1,162 @@
+package stratus
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// unhealthyThreshold is the number of consecutive failed health checks
+	// after which the health monitor attempts to reconnect.
+	unhealthyThreshold = 3
+
+	// healthHandlerTimeout bounds the ping performed by `HealthHandler`.
+	healthHandlerTimeout = 2 * time.Second
+)
+
+// HealthHandler returns a readiness endpoint pinging the primary database,
+// e.g. `mux.Handle("/healthz", stratus.HealthHandler())`. It responds with a
+// 200 when the ping succeeds, and a 503 with a short JSON body when it fails
+// or the database has not been initialized. The ping is bounded by the
+// request context and a timeout of two seconds, whichever ends first.
+func HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthHandlerTimeout)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := Ping(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"unavailable"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}
+}
+
+// WithHealthMonitor starts a background monitor pinging the database every
+// interval. Once three pings in a row have failed, the monitor re-runs the
+// original connect with the same driver, DSN and options, and swaps the new
+// connection in for the old one, which is then closed. Code holding on to
+// the old `*gorm.DB` keeps failing, so fetch it with `GetInstance()` when
+// needed rather than caching it. Connections made with `ConnectWithDB` are
+// monitored but never reconnected, since stratus can't reopen them.
+//
+// The monitor status is exposed through `Healthy()`, and the monitor is
+// stopped by `Close`.
+func WithHealthMonitor(interval time.Duration) ConfigOption {
+	return func(c *config) error {
+		c.healthInterval = interval
+		return nil
+	}
+}
+
+// Healthy reports whether the last health check of the primary database
+// succeeded. Without `WithHealthMonitor`, it only reports whether the database
+// has been initialized.
+func Healthy() bool {
+	mu.RLock()
+	inst, ok := instances[DefaultName]
+	mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+	if inst.monitor == nil {
+		return true
+	}
+
+	return inst.monitor.healthy.Load()
+}
+
+// monitor periodically pings an instance, reconnecting it on sustained
+// failure.
+type monitor struct {
+	healthy atomic.Bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startMonitor starts monitoring inst, registered under name, every
+// interval.
+func startMonitor(name string, inst *instance, interval time.Duration) *monitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &monitor{cancel: cancel, done: make(chan struct{})}
+	m.healthy.Store(true)
+
+	go m.run(ctx, name, inst, interval)
+	return m
+}
+
+// Stop stops the monitor and waits for it to exit. Safe to call on a nil
+// monitor.
+func (m *monitor) Stop() {
+	if m == nil {
+		return
+	}
+
+	m.cancel()
+	<-m.done
+}
+
+func (m *monitor) run(ctx context.Context, name string, inst *instance, interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.check(ctx, inst, interval); err == nil {
+			failures = 0
+			m.healthy.Store(true)
+			continue
+		}
+
+		failures++
+		m.healthy.Store(false)
+		if failures < unhealthyThreshold || inst.open == nil {
+			continue
+		}
+
+		if err := m.reconnect(ctx, name, inst, interval); err == nil {
+			failures = 0
+			m.healthy.Store(true)
+		}
+	}
+}
+
+// check pings the current connection of inst.
+func (m *monitor) check(ctx context.Context, inst *instance, timeout time.Duration) error {
+	mu.RLock()
+	db := inst.db
+	mu.RUnlock()
+
+	sdb, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return sdb.PingContext(ctx)
+}
+
+// reconnect reopens inst, bounded by timeout.
+func (m *monitor) reconnect(ctx context.Context, name string, inst *instance, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return inst.reopen(ctx, name)
+}
@@ -0,0 +1,77 @@
+package stratus
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// queryHookStartKey is the statement instance key the start time of a query is
+// stored under.
+const queryHookStartKey = "stratus:query_hook_start"
+
+// QueryHook receives every statement executed through GORM, see
+// `WithQueryHook`.
+type QueryHook func(ctx context.Context, sql string, duration time.Duration, rowsAffected int64, err error)
+
+// WithQueryHook calls fn after every create, query, update, delete, row and
+// raw statement executed through GORM, including failed ones, with the
+// statement context, its SQL, how long it took, the number of rows affected
+// and its error if any. The SQL holds placeholders rather than the query
+// arguments, so values such as passwords never reach fn. fn runs
+// synchronously on the querying goroutine, hand anything slow off to another
+// one. The option can be passed multiple times, hooks are then called in
+// order.
+func WithQueryHook(fn QueryHook) ConfigOption {
+	return func(c *config) error {
+		if c.queryHooks == nil {
+			c.queryHooks = &queryHookPlugin{}
+			c.plugins = append(c.plugins, c.queryHooks)
+		}
+		c.queryHooks.hooks = append(c.queryHooks.hooks, fn)
+		return nil
+	}
+}
+
+// queryHookPlugin registers the GORM callbacks calling the query hooks.
+type queryHookPlugin struct {
+	hooks []QueryHook
+}
+
+// Name implements `gorm.Plugin`.
+func (p *queryHookPlugin) Name() string {
+	return "stratus:query_hook"
+}
+
+// Initialize implements `gorm.Plugin`.
+func (p *queryHookPlugin) Initialize(db *gorm.DB) error {
+	return registerStatementCallbacks(db, "query_hook", statementCallbacks{
+		before:   p.before,
+		after:    p.after,
+		afterRow: p.after,
+	})
+}
+
+func (p *queryHookPlugin) before(db *gorm.DB) {
+	db.InstanceSet(queryHookStartKey, time.Now())
+}
+
+func (p *queryHookPlugin) after(db *gorm.DB) {
+	var duration time.Duration
+	if v, ok := db.InstanceGet(queryHookStartKey); ok {
+		if start, ok := v.(time.Time); ok {
+			duration = time.Since(start)
+		}
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	sql := db.Statement.SQL.String()
+	for _, hook := range p.hooks {
+		hook(ctx, sql, duration, db.RowsAffected, db.Error)
+	}
+}
@@ -0,0 +1,124 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WithConnectionInitSQL runs stmts, in order, on every new physical
+// connection before it is handed out, e.g. `SET search_path TO tenant` along
+// with a few session settings. Statements run after the connection
+// parameters from the DSN and `WithConnectionParams` have been applied, and
+// passing the option multiple times appends to the statements. A failing
+// statement fails the connection acquisition with its error, so the query
+// that needed the connection fails, as does `Connect` when pinging. Only
+// applies to the Postgres drivers.
+func WithConnectionInitSQL(stmts ...string) ConfigOption {
+	return func(c *config) error {
+		c.initSQL = append(c.initSQL, stmts...)
+		return nil
+	}
+}
+
+// WithSchema pins every Postgres connection to the schema name by setting its
+// `search_path` when the connection is established, before the statements of
+// `WithConnectionInitSQL`, so that unqualified table names resolve to that
+// schema, e.g. one schema per tenant. Only that schema is searched, objects
+// living in other schemas, such as extensions installed in `public`, have to
+// be qualified. The schema itself must already exist.
+//
+// GORM's naming strategy keeps producing unqualified table names, which then
+// resolve to name, unless it is given a `TablePrefix` such as `other.`, which
+// wins over the search path. Migrations create missing tables in name too,
+// since GORM checks for tables in the current schema, i.e. the first schema
+// of the search path. Only applies to the Postgres drivers.
+func WithSchema(name string) ConfigOption {
+	return func(c *config) error {
+		if name == "" {
+			return errors.New("schema name must not be empty")
+		}
+		c.schema = name
+		return nil
+	}
+}
+
+// connectionInitSQL returns the statements to run on every new connection.
+func (c *config) connectionInitSQL() []string {
+	if c.schema == "" {
+		return c.initSQL
+	}
+
+	stmts := []string{"SET search_path TO " + pgx.Identifier{c.schema}.Sanitize()}
+	return append(stmts, c.initSQL...)
+}
+
+// afterConnect runs the connection init statements on a new pgx connection.
+func (c *config) afterConnect(ctx context.Context, conn *pgx.Conn) error {
+	for _, stmt := range c.connectionInitSQL() {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("unable to run connection init sql %q: %w", stmt, err)
+		}
+	}
+
+	return nil
+}
+
+// initSQLConnector opens connections to dsn through drv, and runs the
+// connection init statements on each of them. Used for drivers which, unlike
+// pgx, have no hook of their own to do so.
+type initSQLConnector struct {
+	drv   driver.Driver
+	dsn   string
+	stmts []string
+}
+
+// openInitSQL opens dsn through the driver registered under name, running
+// the connection init statements on every new connection.
+func (c *config) openInitSQL(name, dsn string) (*sql.DB, error) {
+	sdb, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	stmts := c.connectionInitSQL()
+	if len(stmts) == 0 {
+		return sdb, nil
+	}
+
+	// sql.Open doesn't connect, closing it only lets go of the driver handle
+	drv := sdb.Driver()
+	_ = sdb.Close()
+
+	return sql.OpenDB(&initSQLConnector{drv: drv, dsn: dsn, stmts: stmts}), nil
+}
+
+// Connect implements `driver.Connector`.
+func (c *initSQLConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("driver does not support connection init sql")
+	}
+	for _, stmt := range c.stmts {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unable to run connection init sql %q: %w", stmt, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// Driver implements `driver.Connector`.
+func (c *initSQLConnector) Driver() driver.Driver {
+	return c.drv
+}
@@ -0,0 +1,123 @@
+package stratus
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	// listenMinBackoff and listenMaxBackoff bound the wait between two
+	// attempts of `Listen` to get a connection back.
+	listenMinBackoff = 100 * time.Millisecond
+	listenMaxBackoff = 30 * time.Second
+)
+
+// Listen subscribes to the Postgres notification channel, and streams the
+// payload of every `NOTIFY` sent on it until ctx is done, at which point the
+// returned channel is closed. A connection is taken out of the primary
+// database pool for the whole subscription, and is discarded afterwards
+// rather than returned to the pool. When the connection fails, Listen keeps
+// trying to get a new one with an exponential backoff between attempts, up to
+// thirty seconds; notifications sent in the meantime are lost.
+//
+// The subscription is set up before Listen returns, so that an error is
+// returned if it can't be. Payloads have to be received promptly, as a slow
+// receiver holds up the connection. Only the Postgres drivers are supported.
+func Listen(ctx context.Context, channel string) (<-chan string, error) {
+	ch := make(chan string)
+	ready := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+
+		subscribed := false
+		backoff := listenMinBackoff
+		for {
+			err := listen(ctx, channel, ch, func() {
+				if !subscribed {
+					subscribed = true
+					ready <- nil
+				}
+				backoff = listenMinBackoff
+			})
+			if !subscribed {
+				ready <- err
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if backoff *= 2; backoff > listenMaxBackoff {
+				backoff = listenMaxBackoff
+			}
+		}
+	}()
+
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// listen runs `LISTEN channel` on a dedicated connection, calls subscribed
+// once it succeeded, then forwards the notification payloads to ch until
+// either ctx is done or the connection fails.
+func listen(ctx context.Context, channel string, ch chan<- string, subscribed func()) error {
+	sdb, err := sqlDB(DefaultName)
+	if err != nil {
+		return err
+	}
+	sconn, err := sdb.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get a connection: %w", err)
+	}
+	defer sconn.Close()
+
+	_ = sconn.Raw(func(dc any) error {
+		c, ok := dc.(*stdlib.Conn)
+		if !ok {
+			err = errors.New("LISTEN is only supported by the Postgres drivers")
+			return nil
+		}
+		conn := c.Conn()
+
+		// from here on the connection may be left listening, returning
+		// driver.ErrBadConn makes sure it is never handed out again.
+		if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			err = fmt.Errorf("unable to listen on %s: %w", channel, err)
+			return driver.ErrBadConn
+		}
+		subscribed()
+
+		for {
+			var n *pgconn.Notification
+			if n, err = conn.WaitForNotification(ctx); err != nil {
+				return driver.ErrBadConn
+			}
+
+			select {
+			case ch <- n.Payload:
+			case <-ctx.Done():
+				err = ctx.Err()
+				return driver.ErrBadConn
+			}
+		}
+	})
+
+	return err
+}
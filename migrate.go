@@ -0,0 +1,97 @@
+package stratus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// Migrate runs GORM's `AutoMigrate` on the primary database for each of the
+// given models, in order, so that a failure reports which model it happened
+// on. Models referenced by another model's foreign keys must therefore come
+// first. Returns `ErrNotInitialized` if `stratus.Connect` was never called.
+func Migrate(models ...interface{}) error {
+	db, err := lookup(DefaultName)
+	if err != nil {
+		return err
+	}
+
+	for i, model := range models {
+		if err := db.AutoMigrate(model); err != nil {
+			return fmt.Errorf("unable to migrate %T (%d of %d): %w", model, i+1, len(models), err)
+		}
+	}
+
+	return nil
+}
+
+// RunMigrations applies the golang-migrate style migrations found at the root
+// of `migrations` (for example an `embed.FS` of `1_init.up.sql` files) to the
+// primary database and returns the resulting schema version. Running it again
+// once everything is applied is a no-op. The migrations run on a single
+// connection borrowed from the pool, which is returned afterwards; the pool
+// itself stays open. Only the Postgres drivers are supported. If `ctx` is
+// cancelled, the migration in progress is allowed to finish and no further
+// ones are started.
+func RunMigrations(ctx context.Context, migrations fs.FS) (uint, error) {
+	sdb, err := sqlDB(DefaultName)
+	if err != nil {
+		return 0, err
+	}
+	if driver := Driver(); !isPostgres(driver) {
+		return 0, fmt.Errorf("unable to run migrations: %w: %s", ErrUnsupportedDriver, driver)
+	}
+
+	src, err := iofs.New(migrations, ".")
+	if err != nil {
+		return 0, fmt.Errorf("unable to read migrations: %w", err)
+	}
+	defer src.Close()
+
+	conn, err := sdb.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to run migrations: %w", err)
+	}
+	defer conn.Close()
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		return 0, fmt.Errorf("unable to run migrations: %w", err)
+	}
+
+	// m.Close would close the source and the driver, which only owns the
+	// borrowed connection; both are released by the defers above instead.
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		return 0, fmt.Errorf("unable to run migrations: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, fmt.Errorf("unable to run migrations: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("unable to run migrations: %w", err)
+	}
+
+	version, _, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, fmt.Errorf("unable to read migration version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,721 @@
+package stratus
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+
+	cloudsqlconn "github.com/funayman/cloud-sql-go-connector"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// Option configures the database opened by `Connect`. There are two kinds of
+// options, which can be mixed freely in the same call:
+//
+//   - DBOption is applied to the underlying `*sql.DB` once the connection has
+//     been opened, e.g. `WithMaxConnections`.
+//   - ConfigOption is applied to the configuration used to open the
+//     connection, before it is opened, e.g. `WithLogger`.
+//
+// All ConfigOptions are applied first, followed by all DBOptions once the
+// connection is open. Within each kind options are applied in the order given,
+// so the last one wins when two options set the same thing.
+type Option interface {
+	apply(*config) error
+}
+
+// DBOption is an Option applied to the underlying `*sql.DB` once the
+// connection has been opened. Any `func(*sql.DB) error` can be turned into one
+// for custom setup, e.g. `stratus.DBOption(func(db *sql.DB) error { ... })`.
+type DBOption func(*sql.DB) error
+
+func (o DBOption) apply(c *config) error {
+	c.dbOpts = append(c.dbOpts, o)
+	return nil
+}
+
+// ConfigOption is an Option applied to the configuration used to open the
+// connection, before it is opened.
+type ConfigOption func(*config) error
+
+func (o ConfigOption) apply(c *config) error {
+	return o(c)
+}
+
+// config holds everything `Connect` needs to know before opening a connection.
+type config struct {
+	gorm          gorm.Config
+	gormFuncs     []func(*gorm.Config)
+	logger        logger.Interface
+	logWriter     io.Writer
+	logConfig     logger.Config
+	silent        bool
+	dbOpts        []DBOption
+	pingOnConnect bool
+	replicas      []ReplicaConfig
+	plugins       []gorm.Plugin
+	singularTable bool
+	queryHooks    *queryHookPlugin
+
+	// the idle connection limit set on the `*sql.DB`, unknown for connections
+	// opened by the caller until an option sets it
+	maxIdleConns      int
+	maxIdleConnsKnown bool
+
+	simpleProtocol    bool
+	pgBouncer         bool
+	defaultStringSize uint
+
+	tlsConfig        *tls.Config
+	statementTimeout time.Duration
+	dialTimeout      time.Duration
+	connParams       map[string]string
+	timeZone         string
+	applicationName  string
+	readOnly         bool
+	initSQL          []string
+	schema           string
+
+	healthInterval time.Duration
+	startupLogger  *slog.Logger
+	driver         string
+	external       bool
+
+	cloudSQLCredentialsFile string
+	cloudSQLIAMAuth         bool
+	cloudSQLDialOptions     []cloudsqlconn.DialOption
+	cloudSQLDriver          string
+	cloudSQLCleanup         func() error
+}
+
+// newConfig applies opts on top of the defaults.
+func newConfig(opts []Option) (*config, error) {
+	c := &config{
+		logConfig: logger.Config{
+			SlowThreshold:             time.Second,  // Slow SQL threshold
+			LogLevel:                  logger.Error, // Log level
+			IgnoreRecordNotFoundError: true,         // Ignore ErrRecordNotFound error for logger
+			Colorful:                  false,        // Disable color
+		},
+		logWriter:               os.Stdout,
+		pingOnConnect:           true,
+		cloudSQLCredentialsFile: defaultCloudSQLCredentialsFile,
+		cloudSQLIAMAuth:         true,
+	}
+	for _, opt := range opts {
+		if err := opt.apply(c); err != nil {
+			return nil, fmt.Errorf("config opts failure: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// release releases what was acquired while opening a connection with c and
+// outlives the connection itself, such as the Cloud SQL connector.
+func (c *config) release() error {
+	if c.cloudSQLCleanup == nil {
+		return nil
+	}
+
+	if err := c.cloudSQLCleanup(); err != nil {
+		return fmt.Errorf("unable to close cloud sql connector: %w", err)
+	}
+	return nil
+}
+
+// gormConfig builds the `*gorm.Config` used to open the connection.
+func (c *config) gormConfig() *gorm.Config {
+	l := c.logger
+	if l == nil {
+		l = logger.New(
+			log.New(c.logWriter, "\r\n", log.LstdFlags), // io writer
+			c.logConfig,
+		)
+	}
+	if c.silent {
+		l = l.LogMode(logger.Silent)
+	}
+
+	cfg := c.gorm
+	cfg.Logger = l
+	if c.pgBouncer {
+		cfg.PrepareStmt = false
+	}
+	if c.singularTable && cfg.NamingStrategy == nil {
+		cfg.NamingStrategy = schema.NamingStrategy{SingularTable: true}
+	}
+	// the connection is verified with ctx once opened by `Connect` instead
+	cfg.DisableAutomaticPing = true
+
+	for _, fn := range c.gormFuncs {
+		fn(&cfg)
+	}
+
+	return &cfg
+}
+
+// WithLogger replaces the default GORM logger, which writes plain text lines
+// to stdout, with l. Use it to route GORM logs through a structured logger.
+// Options tuning the default logger, such as `WithSlowThreshold`, have no
+// effect on l.
+func WithLogger(l logger.Interface) ConfigOption {
+	return func(c *config) error {
+		c.logger = l
+		return nil
+	}
+}
+
+// WithLogWriter sets where the default GORM logger writes to, e.g. `os.Stderr`
+// or a buffer capturing the output in tests. Defaults to `os.Stdout`.
+func WithLogWriter(w io.Writer) ConfigOption {
+	return func(c *config) error {
+		if w == nil {
+			return errors.New("log writer must not be nil")
+		}
+		c.logWriter = w
+		return nil
+	}
+}
+
+// WithSlowThreshold sets the duration after which the default GORM logger
+// reports a query as slow. Defaults to one second. Zero disables slow query
+// logging entirely rather than flagging every query, which suits jobs whose
+// queries are expected to run for minutes; negative values are rejected.
+func WithSlowThreshold(d time.Duration) ConfigOption {
+	return func(c *config) error {
+		if d < 0 {
+			return fmt.Errorf("slow threshold must not be negative, got %s", d)
+		}
+		c.logConfig.SlowThreshold = d
+		return nil
+	}
+}
+
+// WithLogLevel sets the level of the default GORM logger to one of
+// `logger.Silent`, `logger.Error`, `logger.Warn` or `logger.Info`. Defaults to
+// `logger.Error`; use `logger.Info` to see every query during development.
+func WithLogLevel(level logger.LogLevel) ConfigOption {
+	return func(c *config) error {
+		c.logConfig.LogLevel = level
+		return nil
+	}
+}
+
+// WithColorfulLogger toggles ANSI colors in the default GORM logger output,
+// which eases reading logs in a local terminal. Defaults to false, leave it
+// off when logs are collected by an aggregator.
+func WithColorfulLogger(enabled bool) ConfigOption {
+	return func(c *config) error {
+		c.logConfig.Colorful = enabled
+		return nil
+	}
+}
+
+// WithLogRecordNotFound makes the default GORM logger report
+// `gorm.ErrRecordNotFound` errors, which it ignores by default, for services
+// where a missing record points at a data-integrity bug.
+func WithLogRecordNotFound(enabled bool) ConfigOption {
+	return func(c *config) error {
+		c.logConfig.IgnoreRecordNotFoundError = !enabled
+		return nil
+	}
+}
+
+// WithHideParameterValues makes the default GORM logger log queries with
+// their placeholders rather than the bound values inlined, so that personal
+// data passed as query arguments stays out of the logs.
+func WithHideParameterValues() ConfigOption {
+	return func(c *config) error {
+		c.logConfig.ParameterizedQueries = true
+		return nil
+	}
+}
+
+// WithSilentLogger disables GORM logging entirely, which is useful for batch
+// jobs where stdout is reserved for other output. It takes precedence over
+// `WithLogLevel`, and also silences a logger supplied through `WithLogger`.
+func WithSilentLogger() ConfigOption {
+	return func(c *config) error {
+		c.silent = true
+		return nil
+	}
+}
+
+// WithPrepareStmt enables GORM's prepared statement cache, which saves parsing
+// overhead on repeated queries. Leave it off when connecting through pgbouncer
+// in transaction pooling mode, since prepared statements are tied to a server
+// connection that may be handed to another client between statements.
+func WithPrepareStmt() ConfigOption {
+	return func(c *config) error {
+		c.gorm.PrepareStmt = true
+		return nil
+	}
+}
+
+// WithPgBouncerMode makes the connection safe to use through pgbouncer in
+// transaction pooling mode, where consecutive statements may land on different
+// server connections. It switches the Postgres driver to the simple query
+// protocol, so that the driver no longer relies on implicitly prepared
+// statements, and turns off `WithPrepareStmt` regardless of the order the two
+// options are given in.
+//
+// With the `cloudsql-postgres` driver the simple protocol is requested by
+// adding `default_query_exec_mode=simple_protocol` to the DSN, since the
+// connector opens the DSN itself. Has no effect on non-Postgres drivers.
+func WithPgBouncerMode() ConfigOption {
+	return func(c *config) error {
+		c.pgBouncer = true
+		c.simpleProtocol = true
+		return nil
+	}
+}
+
+// WithSimpleProtocol switches the Postgres driver to pgx's simple query
+// protocol, which sends each parameterized query in a single round trip
+// instead of the extended protocol's parse, describe and execute steps. This
+// cuts latency for large analytics queries, at the cost of the server no
+// longer inferring parameter types; values are sent as text instead.
+//
+// The query arguments are interpolated into the SQL on the client, escaped by
+// pgx, rather than sent separately from the statement. Keep passing values as
+// arguments, never by formatting them into the SQL yourself, and note that
+// they now appear in the query text seen by the server, e.g. in
+// `pg_stat_activity` and the server logs.
+//
+// Applies to the `postgres`, `pgx` and `cloudsql-postgres` drivers; has no
+// effect on other drivers or on connections opened by the caller, such as
+// those passed to `ConnectWithPgxPool`.
+func WithSimpleProtocol() ConfigOption {
+	return func(c *config) error {
+		c.simpleProtocol = true
+		return nil
+	}
+}
+
+// WithSkipDefaultTransaction stops GORM from wrapping every create, update
+// and delete in its own transaction, which adds measurable overhead to bulk
+// inserts. Explicit transactions are unaffected.
+func WithSkipDefaultTransaction() ConfigOption {
+	return func(c *config) error {
+		c.gorm.SkipDefaultTransaction = true
+		return nil
+	}
+}
+
+// WithDisableForeignKeyConstraintWhenMigrating stops `AutoMigrate`, and so
+// `stratus.Migrate`, from creating foreign key constraints, for schemas whose
+// foreign keys are managed out of band. Associations keep working as before.
+func WithDisableForeignKeyConstraintWhenMigrating() ConfigOption {
+	return func(c *config) error {
+		c.gorm.DisableForeignKeyConstraintWhenMigrating = true
+		return nil
+	}
+}
+
+// WithTranslateError has GORM translate driver errors into its own, such as
+// `gorm.ErrDuplicatedKey` and `gorm.ErrForeignKeyViolated`, so that they can
+// be checked with `errors.Is` regardless of the driver. The translated errors
+// no longer wrap the driver's, so `AsDBError` can't classify them anymore.
+func WithTranslateError() ConfigOption {
+	return func(c *config) error {
+		c.gorm.TranslateError = true
+		return nil
+	}
+}
+
+// WithDisableNestedTransaction stops GORM from wrapping a `Transaction`
+// started inside another one in a savepoint, for code managing its savepoints
+// itself with `SavePoint` and `RollbackTo`. The nested function then runs
+// directly in the outer transaction, so an error it returns no longer rolls
+// back only its own changes.
+func WithDisableNestedTransaction() ConfigOption {
+	return func(c *config) error {
+		c.gorm.DisableNestedTransaction = true
+		return nil
+	}
+}
+
+// WithDefaultStringSize sets the size of string columns created by migrations
+// when the model doesn't specify one, e.g. `varchar(n)` rather than
+// `longtext`. Only the `mysql` and `sqlserver` drivers honor it; Postgres and
+// SQLite create unbounded text columns either way, and ignore it.
+func WithDefaultStringSize(n uint) ConfigOption {
+	return func(c *config) error {
+		c.defaultStringSize = n
+		return nil
+	}
+}
+
+// WithNowFunc replaces the clock GORM uses to fill `CreatedAt` and
+// `UpdatedAt` fields, so that tests can freeze time and assert on them.
+// Defaults to the current local time.
+func WithNowFunc(fn func() time.Time) ConfigOption {
+	return func(c *config) error {
+		if fn == nil {
+			return errors.New("now func must not be nil")
+		}
+		c.gorm.NowFunc = fn
+		return nil
+	}
+}
+
+// WithDryRun makes GORM build statements without executing them, so that the
+// generated SQL can be captured from `Statement.SQL`, e.g. by a migration diff
+// tool. Every statement ran through the database is affected. Defaults to
+// off.
+func WithDryRun() ConfigOption {
+	return func(c *config) error {
+		c.gorm.DryRun = true
+		return nil
+	}
+}
+
+// WithCreateBatchSize makes GORM split the creation of slices into batches of
+// n records, as `CreateInBatches` does, for every `Create` call. n must be
+// positive.
+func WithCreateBatchSize(n int) ConfigOption {
+	return func(c *config) error {
+		if n <= 0 {
+			return fmt.Errorf("create batch size must be positive, got %d", n)
+		}
+		c.gorm.CreateBatchSize = n
+		return nil
+	}
+}
+
+// WithQueryFields makes GORM select the columns of the model by name rather
+// than with `SELECT *`, which eases debugging and keeps queries working when
+// the table has columns the model lacks. Defaults to off.
+func WithQueryFields() ConfigOption {
+	return func(c *config) error {
+		c.gorm.QueryFields = true
+		return nil
+	}
+}
+
+// WithFullSaveAssociations makes GORM save every association of a record when
+// the record is saved, updating existing associated records rather than only
+// creating missing ones, as deeply nested models need. Defaults to off.
+func WithFullSaveAssociations() ConfigOption {
+	return func(c *config) error {
+		c.gorm.FullSaveAssociations = true
+		return nil
+	}
+}
+
+// WithSingularTable keeps GORM's default naming strategy, but with singular
+// table names, e.g. `user` rather than `users` for a `User` model, as found in
+// many legacy schemas. A strategy given to `WithNamingStrategy` wins over it,
+// whatever the order of the options.
+func WithSingularTable() ConfigOption {
+	return func(c *config) error {
+		c.singularTable = true
+		return nil
+	}
+}
+
+// WithNamingStrategy replaces GORM's default naming strategy, which
+// pluralizes table names and snake cases columns, with ns. Useful when
+// onboarding legacy schemas that follow a different convention.
+func WithNamingStrategy(ns schema.Namer) ConfigOption {
+	return func(c *config) error {
+		c.gorm.NamingStrategy = ns
+		return nil
+	}
+}
+
+// WithGormConfig is an escape hatch for the `gorm.Config` fields that have no
+// dedicated option, such as `AllowGlobalUpdate`. fn is
+// called with the fully built config immediately before `gorm.Open`, after
+// every other option has been applied, so the logger set up by stratus is
+// left alone unless fn deliberately replaces it.
+func WithGormConfig(fn func(*gorm.Config)) ConfigOption {
+	return func(c *config) error {
+		c.gormFuncs = append(c.gormFuncs, fn)
+		return nil
+	}
+}
+
+// WithPingOnConnect toggles pinging the database once the connection has been
+// opened, which is enabled by default so that `Connect` fails fast against an
+// unreachable database. Disable it to defer connecting until the first query.
+func WithPingOnConnect(enabled bool) ConfigOption {
+	return func(c *config) error {
+		c.pingOnConnect = enabled
+		return nil
+	}
+}
+
+// WithStatementTimeout sets the Postgres `statement_timeout` of every
+// connection, so that the server aborts any statement running longer than d.
+// The timeout is enforced by the server and complements, rather than
+// replaces, context deadlines: it also covers queries issued without one, and
+// releases server resources even if the client has gone away. Only applies to
+// the Postgres drivers, and takes precedence over a `statement_timeout`
+// already set in the DSN.
+func WithStatementTimeout(d time.Duration) ConfigOption {
+	return func(c *config) error {
+		if d < 0 {
+			return fmt.Errorf("statement timeout must not be negative, got %s", d)
+		}
+		c.statementTimeout = d
+		return nil
+	}
+}
+
+// WithDialTimeout bounds the network dial of every new connection, so that an
+// unreachable host fails fast. Unlike `ConnectContext`'s deadline or the
+// `connect_timeout` DSN parameter, it leaves the rest of the connection
+// process, such as TLS and authentication, unbounded; both still cut a dial
+// short when they expire first. For `cloudsql-postgres` it only bounds the
+// dial to the instance, not the connector fetching certificates and tokens.
+// Only applies to the Postgres drivers.
+func WithDialTimeout(d time.Duration) ConfigOption {
+	return func(c *config) error {
+		if d < 0 {
+			return fmt.Errorf("dial timeout must not be negative, got %s", d)
+		}
+		c.dialTimeout = d
+		return nil
+	}
+}
+
+// dialFunc dials addr on the named network.
+type dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// timeoutDialFunc bounds every dial made through dial by timeout.
+func timeoutDialFunc(dial dialFunc, timeout time.Duration) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return dial(ctx, network, addr)
+	}
+}
+
+// WithApplicationName sets the Postgres `application_name` of every
+// connection, which tells services apart in `pg_stat_activity`, e.g. with
+// `SELECT application_name, count(*) FROM pg_stat_activity GROUP BY 1`. It
+// takes precedence over an `application_name` set in the DSN or through
+// `WithConnectionParams`, whatever the case of its name. When none of them
+// sets it, the name of the running executable is used. Only applies to the
+// Postgres drivers.
+func WithApplicationName(name string) ConfigOption {
+	return func(c *config) error {
+		if name == "" {
+			return errors.New("application name must not be empty")
+		}
+		c.applicationName = name
+		return nil
+	}
+}
+
+// timeZonePattern matches plausible IANA time zone names, such as `UTC` or
+// `America/Argentina/Buenos_Aires`.
+var timeZonePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_+-]*(/[A-Za-z0-9_+-]+)*$`)
+
+// WithTimeZone sets the Postgres session `TimeZone` of every connection to
+// tz, an IANA time zone name such as `UTC`, for providers whose servers
+// default to a local zone. It takes precedence over a `TimeZone` set in the
+// DSN or through `WithConnectionParams`, whatever the case of its name. Only
+// applies to the Postgres drivers.
+func WithTimeZone(tz string) ConfigOption {
+	return func(c *config) error {
+		if !timeZonePattern.MatchString(tz) {
+			return fmt.Errorf("invalid time zone %q", tz)
+		}
+		c.timeZone = tz
+		return nil
+	}
+}
+
+// WithReadOnly makes every connection read-only by setting the Postgres
+// `default_transaction_read_only` parameter when it starts up, so that any
+// write fails with an error. It is a cheap safety net for services that must
+// never write, and documents the intent when connecting to a replica. Connect
+// fails when the option is used with a driver other than the Postgres ones,
+// or with `ConnectWithDB`, rather than silently allowing writes.
+func WithReadOnly() ConfigOption {
+	return func(c *config) error {
+		c.readOnly = true
+		return nil
+	}
+}
+
+// connParamPattern matches the names of Postgres server parameters, including
+// custom ones qualified by an extension or application prefix, such as
+// `myapp.tenant`.
+var connParamPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// WithConnectionParams sets server parameters, such as `application_name`,
+// `search_path` or `TimeZone`, on every Postgres connection by adding them to
+// the DSN, with values quoted or escaped as needed. Names have to be plain
+// identifiers, anything else is rejected. A parameter already present in the
+// DSN is overridden by params, and `WithStatementTimeout` takes precedence
+// over a `statement_timeout` in params. Calling the option multiple times
+// merges the maps. Only applies to the Postgres drivers.
+func WithConnectionParams(params map[string]string) ConfigOption {
+	return func(c *config) error {
+		if c.connParams == nil {
+			c.connParams = make(map[string]string, len(params))
+		}
+		for k, v := range params {
+			if k == "" {
+				return errors.New("connection param name must not be empty")
+			}
+			if !connParamPattern.MatchString(k) {
+				return fmt.Errorf("invalid connection param name %q", k)
+			}
+			c.connParams[k] = v
+		}
+		return nil
+	}
+}
+
+// WithMaxConnections allows for the setting of `MaxOpenConns` for the
+// underlying `*sql.DB` instance during database initialization. A value of
+// zero means there is no limit on the number of open connections; negative
+// values are rejected.
+func WithMaxConnections(max int) DBOption {
+	return func(db *sql.DB) error {
+		if max < 0 {
+			return fmt.Errorf("max connections must not be negative, got %d", max)
+		}
+		db.SetMaxOpenConns(max)
+		return nil
+	}
+}
+
+// WithAutoMaxConnections sizes the connection pool from the number of CPUs
+// the process may use, so that services share one heuristic instead of
+// hardcoding their own: `MaxOpenConns` is set to four times
+// `runtime.GOMAXPROCS(0)`, and `MaxIdleConns` to half of that. Options are
+// applied in order, so pass `WithMaxConnections` or `WithMaxIdleConnections`
+// after it to override either value.
+func WithAutoMaxConnections() DBOption {
+	return func(db *sql.DB) error {
+		max := runtime.GOMAXPROCS(0) * 4
+		db.SetMaxOpenConns(max)
+		setMaxIdleConns(db, max/2)
+		return nil
+	}
+}
+
+// WithMaxIdleConnections allows for the setting of `MaxIdleConns` for the
+// underlying `*sql.DB` instance during database initialization. A value of
+// zero means no idle connections are retained, consistent with `database/sql`;
+// negative values are rejected.
+func WithMaxIdleConnections(max int) DBOption {
+	return func(db *sql.DB) error {
+		if max < 0 {
+			return fmt.Errorf("max idle connections must not be negative, got %d", max)
+		}
+		setMaxIdleConns(db, max)
+		return nil
+	}
+}
+
+// defaultMaxIdleConns is the `MaxIdleConns` limit of a `*sql.DB` no option
+// changed, see `(*sql.DB).SetMaxIdleConns`.
+const defaultMaxIdleConns = 2
+
+// idleConnRecorders maps every `*sql.DB` `applyDBOptions` is applying DB
+// options to, to the config recording the idle connection limit they set,
+// which `database/sql` doesn't expose. Entries only exist while the options
+// are applied, so options applied by callers to their own `*sql.DB` leave
+// nothing behind.
+var (
+	idleConnMu        sync.Mutex
+	idleConnRecorders = map[*sql.DB]*config{}
+)
+
+// setMaxIdleConns sets the `MaxIdleConns` limit of db to n, and records it in
+// the config of db, if its DB options are being applied.
+func setMaxIdleConns(db *sql.DB, n int) {
+	db.SetMaxIdleConns(n)
+
+	idleConnMu.Lock()
+	c, ok := idleConnRecorders[db]
+	idleConnMu.Unlock()
+	if !ok {
+		return
+	}
+	if n < 0 {
+		n = 0
+	}
+	c.maxIdleConns, c.maxIdleConnsKnown = n, true
+}
+
+// WithConnMaxLifetime allows for the setting of `ConnMaxLifetime` for the
+// underlying `*sql.DB` instance during database initialization. Connections
+// older than `d` are closed and replaced; a value of zero means connections
+// are reused forever, consistent with `database/sql`.
+func WithConnMaxLifetime(d time.Duration) DBOption {
+	return func(db *sql.DB) error {
+		db.SetConnMaxLifetime(d)
+		return nil
+	}
+}
+
+// WithConnMaxIdleTime allows for the setting of `ConnMaxIdleTime` for the
+// underlying `*sql.DB` instance during database initialization. Connections
+// idle for longer than `d` are closed; a value of zero means connections are
+// never closed due to idle time.
+func WithConnMaxIdleTime(d time.Duration) DBOption {
+	return func(db *sql.DB) error {
+		db.SetConnMaxIdleTime(d)
+		return nil
+	}
+}
+
+// PoolConfig groups the `*sql.DB` connection pool settings applied by
+// `WithPool`. Zero-valued fields are left untouched, so the driver defaults
+// apply to them.
+type PoolConfig struct {
+	MaxOpen     int           // see WithMaxConnections
+	MaxIdle     int           // see WithMaxIdleConnections
+	MaxLifetime time.Duration // see WithConnMaxLifetime
+	MaxIdleTime time.Duration // see WithConnMaxIdleTime
+}
+
+// WithPool applies every set field of cfg to the underlying `*sql.DB`
+// instance during database initialization, keeping the pool settings of a
+// service in a single place.
+func WithPool(cfg PoolConfig) DBOption {
+	return func(db *sql.DB) error {
+		var opts []DBOption
+		if cfg.MaxOpen != 0 {
+			opts = append(opts, WithMaxConnections(cfg.MaxOpen))
+		}
+		if cfg.MaxIdle != 0 {
+			opts = append(opts, WithMaxIdleConnections(cfg.MaxIdle))
+		}
+		if cfg.MaxLifetime != 0 {
+			opts = append(opts, WithConnMaxLifetime(cfg.MaxLifetime))
+		}
+		if cfg.MaxIdleTime != 0 {
+			opts = append(opts, WithConnMaxIdleTime(cfg.MaxIdleTime))
+		}
+
+		for _, opt := range opts {
+			if err := opt(db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,387 @@
+package stratus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// testUser is a model for tests that need a table.
+type testUser struct {
+	ID   uint
+	Name string
+}
+
+// tableName resolves the table name db maps model to.
+func tableName(t *testing.T, db *gorm.DB, model interface{}) string {
+	t.Helper()
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		t.Fatalf("Parse(%T) error = %v", model, err)
+	}
+
+	return stmt.Schema.Table
+}
+
+func TestWithSlowThreshold(t *testing.T) {
+	c, err := newConfig([]Option{WithSlowThreshold(250 * time.Millisecond)})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.logConfig.SlowThreshold; got != 250*time.Millisecond {
+		t.Errorf("logConfig.SlowThreshold = %s, want 250ms", got)
+	}
+
+	if _, err := newConfig([]Option{WithSlowThreshold(-time.Second)}); err == nil {
+		t.Error("newConfig() with a negative slow threshold succeeded, want an error")
+	}
+}
+
+func TestWithSlowThresholdZeroDisables(t *testing.T) {
+	var buf bytes.Buffer
+	db := connectSQLite(t,
+		WithLogWriter(&buf),
+		WithLogLevel(logger.Warn),
+		WithSlowThreshold(0),
+		// makes every statement take longer than any threshold would allow
+		WithQueryHook(func(context.Context, string, time.Duration, int64, error) {
+			time.Sleep(10 * time.Millisecond)
+		}),
+	)
+
+	if err := db.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("SELECT 1 error = %v", err)
+	}
+	if strings.Contains(buf.String(), "SLOW SQL") {
+		t.Errorf("logged a slow query with a zero threshold:\n%s", buf.String())
+	}
+}
+
+func TestWithNamingStrategy(t *testing.T) {
+	db := connectSQLite(t, WithNamingStrategy(schema.NamingStrategy{TablePrefix: "legacy_"}))
+
+	if got := tableName(t, db, &testUser{}); got != "legacy_test_users" {
+		t.Errorf("table name = %q, want %q", got, "legacy_test_users")
+	}
+}
+
+func TestWithSkipDefaultTransaction(t *testing.T) {
+	db := connectSQLite(t, WithSkipDefaultTransaction())
+
+	if !db.Config.SkipDefaultTransaction {
+		t.Error("SkipDefaultTransaction = false, want true")
+	}
+}
+
+func TestWithLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	db := connectSQLite(t,
+		WithLogWriter(&buf),
+		WithLogLevel(logger.Warn),
+		WithSlowThreshold(time.Nanosecond),
+	)
+
+	if err := db.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("SELECT 1 error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "SLOW SQL") {
+		t.Errorf("log output = %q, want a slow query line", buf.String())
+	}
+}
+
+func TestWithConnectionParams(t *testing.T) {
+	c, err := newConfig([]Option{WithConnectionParams(map[string]string{
+		"application_name": "billing worker",
+		"myapp.tenant":     "it's",
+	})})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	cfg, err := pgconn.ParseConfig(c.postgresDSN("host=db user=app"))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	for k, want := range map[string]string{"application_name": "billing worker", "myapp.tenant": "it's"} {
+		if got := cfg.RuntimeParams[k]; got != want {
+			t.Errorf("RuntimeParams[%s] = %q, want %q", k, got, want)
+		}
+	}
+
+	for _, name := range []string{"", "bad name", "a=b", "x' password='y", "1st"} {
+		if _, err := newConfig([]Option{WithConnectionParams(map[string]string{name: "v"})}); err == nil {
+			t.Errorf("WithConnectionParams(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestWithDisableForeignKeyConstraintWhenMigrating(t *testing.T) {
+	db := connectSQLite(t, WithDisableForeignKeyConstraintWhenMigrating())
+
+	if !db.Config.DisableForeignKeyConstraintWhenMigrating {
+		t.Error("DisableForeignKeyConstraintWhenMigrating = false, want true")
+	}
+}
+
+func TestWithNowFunc(t *testing.T) {
+	type event struct {
+		ID        uint
+		CreatedAt time.Time
+	}
+
+	now := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	db := connectSQLite(t, WithNowFunc(func() time.Time { return now }))
+	if err := db.AutoMigrate(&event{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+
+	e := event{}
+	if err := db.Create(&e).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !e.CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt = %s, want %s", e.CreatedAt, now)
+	}
+
+	var stored event
+	if err := db.First(&stored, e.ID).Error; err != nil {
+		t.Fatalf("First() error = %v", err)
+	}
+	if !stored.CreatedAt.Equal(now) {
+		t.Errorf("stored CreatedAt = %s, want %s", stored.CreatedAt, now)
+	}
+}
+
+func TestWithLogRecordNotFound(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		c, err := newConfig([]Option{WithLogRecordNotFound(enabled)})
+		if err != nil {
+			t.Fatalf("newConfig() error = %v", err)
+		}
+		if got := c.logConfig.IgnoreRecordNotFoundError; got != !enabled {
+			t.Errorf("WithLogRecordNotFound(%t): IgnoreRecordNotFoundError = %t, want %t", enabled, got, !enabled)
+		}
+	}
+
+	c, err := newConfig(nil)
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !c.logConfig.IgnoreRecordNotFoundError {
+		t.Error("IgnoreRecordNotFoundError = false by default, want true")
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	c, err := newConfig([]Option{WithReadOnly()})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	cfg, err := pgconn.ParseConfig(c.postgresDSN("host=db user=app"))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if got := cfg.RuntimeParams["default_transaction_read_only"]; got != "on" {
+		t.Errorf("default_transaction_read_only = %q, want on", got)
+	}
+
+	if _, err := c.dialector("sqlite", ":memory:"); err == nil {
+		t.Error("dialector(sqlite) with WithReadOnly succeeded, want an error")
+	}
+}
+
+func TestWithDryRun(t *testing.T) {
+	db := connectSQLite(t, WithDryRun())
+
+	// the table doesn't exist, which doesn't matter as nothing is executed
+	var users []testUser
+	stmt := db.Where("name = ?", "gopher").Find(&users).Statement
+	if stmt.Error != nil {
+		t.Fatalf("Find() error = %v", stmt.Error)
+	}
+
+	if got, want := stmt.SQL.String(), "SELECT * FROM `test_users` WHERE name = ?"; got != want {
+		t.Errorf("Statement.SQL = %q, want %q", got, want)
+	}
+	if len(stmt.Vars) != 1 || stmt.Vars[0] != "gopher" {
+		t.Errorf("Statement.Vars = %v, want [gopher]", stmt.Vars)
+	}
+}
+
+func TestWithQueryFields(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "SELECT * FROM `test_users`"},
+		{[]Option{WithQueryFields()}, "SELECT `test_users`.`id`,`test_users`.`name` FROM `test_users`"},
+	}
+	for _, tt := range tests {
+		db := connectSQLite(t, tt.opts...)
+
+		var users []testUser
+		stmt := db.Session(&gorm.Session{DryRun: true}).Find(&users).Statement
+		if got := stmt.SQL.String(); got != tt.want {
+			t.Errorf("Statement.SQL = %q, want %q", got, tt.want)
+		}
+		Reset()
+	}
+}
+
+func TestWithSingularTable(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"singular", []Option{WithSingularTable()}, "test_user"},
+		// the explicit strategy wins, whatever the order of the options
+		{"strategy after", []Option{WithSingularTable(), WithNamingStrategy(schema.NamingStrategy{TablePrefix: "legacy_"})}, "legacy_test_users"},
+		{"strategy before", []Option{WithNamingStrategy(schema.NamingStrategy{TablePrefix: "legacy_"}), WithSingularTable()}, "legacy_test_users"},
+	}
+	for _, tt := range tests {
+		db := connectSQLite(t, tt.opts...)
+		if got := tableName(t, db, &testUser{}); got != tt.want {
+			t.Errorf("%s: table name = %q, want %q", tt.name, got, tt.want)
+		}
+		Reset()
+	}
+}
+
+func TestWithFullSaveAssociations(t *testing.T) {
+	type profile struct {
+		ID       uint
+		ParentID uint
+		Bio      string
+	}
+	type parent struct {
+		ID      uint
+		Profile profile `gorm:"foreignKey:ParentID"`
+	}
+
+	for _, full := range []bool{true, false} {
+		var opts []Option
+		if full {
+			opts = append(opts, WithFullSaveAssociations())
+		}
+		db := connectSQLite(t, opts...)
+		if err := db.AutoMigrate(&parent{}, &profile{}); err != nil {
+			t.Fatalf("AutoMigrate() error = %v", err)
+		}
+
+		p := parent{Profile: profile{Bio: "before"}}
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		p.Profile.Bio = "after"
+		if err := db.Save(&p).Error; err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		var stored profile
+		if err := db.First(&stored, p.Profile.ID).Error; err != nil {
+			t.Fatalf("First() error = %v", err)
+		}
+		want := "before"
+		if full {
+			want = "after"
+		}
+		if stored.Bio != want {
+			t.Errorf("full save %t: Bio = %q, want %q", full, stored.Bio, want)
+		}
+		Reset()
+	}
+}
+
+func TestWithTranslateError(t *testing.T) {
+	db := connectSQLite(t, WithTranslateError())
+	if err := db.AutoMigrate(&testUser{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+
+	if err := db.Create(&testUser{ID: 1, Name: "gopher"}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	err := db.Create(&testUser{ID: 1, Name: "duplicate"}).Error
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("Create() with a duplicate primary key error = %v, want gorm.ErrDuplicatedKey", err)
+	}
+}
+
+func TestWithDisableNestedTransaction(t *testing.T) {
+	for _, disabled := range []bool{true, false} {
+		var statements []string
+		opts := []Option{WithQueryHook(func(_ context.Context, sql string, _ time.Duration, _ int64, _ error) {
+			statements = append(statements, sql)
+		})}
+		if disabled {
+			opts = append(opts, WithDisableNestedTransaction())
+		}
+		db := connectSQLite(t, opts...)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.Transaction(func(tx *gorm.DB) error {
+				return tx.Exec("SELECT 1").Error
+			})
+		})
+		if err != nil {
+			t.Fatalf("Transaction() error = %v", err)
+		}
+
+		savepoint := false
+		for _, sql := range statements {
+			if strings.HasPrefix(sql, "SAVEPOINT") {
+				savepoint = true
+			}
+		}
+		if savepoint == disabled {
+			t.Errorf("nested transactions disabled %t: issued a savepoint %t, statements %q", disabled, savepoint, statements)
+		}
+		Reset()
+	}
+}
+
+func TestWithTimeZoneOverridesAnyCase(t *testing.T) {
+	c, err := newConfig([]Option{
+		WithTimeZone("UTC"),
+		WithApplicationName("billing"),
+		WithConnectionParams(map[string]string{"TIMEZONE": "Europe/Paris", "Application_Name": "params"}),
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	for _, dsn := range []string{
+		"host=db user=app timezone=America/New_York APPLICATION_NAME=dsn",
+		"postgres://app@db/app?timezone=America%2FNew_York&APPLICATION_NAME=dsn",
+	} {
+		cfg, err := pgconn.ParseConfig(c.postgresDSN(dsn))
+		if err != nil {
+			t.Fatalf("ParseConfig() error = %v", err)
+		}
+
+		want := map[string]string{"TimeZone": "UTC", "application_name": "billing"}
+		for k, v := range cfg.RuntimeParams {
+			if strings.EqualFold(k, "TimeZone") || strings.EqualFold(k, "application_name") {
+				if want[k] != v {
+					t.Errorf("%s: RuntimeParams[%s] = %q, want only %v", dsn, k, v, want)
+				}
+			}
+		}
+		for k, v := range want {
+			if got := cfg.RuntimeParams[k]; got != v {
+				t.Errorf("%s: RuntimeParams[%s] = %q, want %q", dsn, k, got, v)
+			}
+		}
+	}
+}
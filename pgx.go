@@ -0,0 +1,65 @@
+package stratus
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ConnectWithPgxPool behaves like `ConnectWithDB`, but opens GORM on top of
+// an existing pgx pool, so that GORM and the code using pool directly share
+// its connections rather than each holding a pool of their own. The
+// `*sql.DB` bridging GORM to pool keeps no idle connections, idle ones are
+// all kept by pool, so leave `WithMaxIdleConnections` out; the pool size is
+// set on pool itself. pool stays owned by the caller: `Close` only closes the
+// bridge, and pool has to be closed by the caller afterwards.
+func ConnectWithPgxPool(pool *pgxpool.Pool, opts ...Option) (*gorm.DB, error) {
+	return ConnectWithDB("pgx", stdlib.OpenDBFromPool(pool), opts...)
+}
+
+// usesPgxConfig reports whether the options in c need `pgxDialector` to be
+// applied to a plain Postgres connection.
+func (c *config) usesPgxConfig() bool {
+	return c.tlsConfig != nil || c.dialTimeout > 0 || len(c.connectionInitSQL()) > 0
+}
+
+// pgxDialector returns a postgres dialector opening dsn through a pgx
+// connection config, for settings that can't be expressed in the DSN.
+func (c *config) pgxDialector(dsn string) (gorm.Dialector, error) {
+	cfg, err := c.pgxConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []stdlib.OptionOpenDB
+	if len(c.connectionInitSQL()) > 0 {
+		opts = append(opts, stdlib.OptionAfterConnect(c.afterConnect))
+	}
+
+	return postgres.New(postgres.Config{Conn: stdlib.OpenDB(*cfg, opts...)}), nil
+}
+
+// pgxConfig parses dsn into a pgx connection config, and applies the
+// settings of c to it.
+func (c *config) pgxConfig(dsn string) (*pgx.ConnConfig, error) {
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse dsn: %w", err)
+	}
+
+	if c.simpleProtocol {
+		cfg.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	}
+	if c.tlsConfig != nil {
+		c.applyTLSConfig(&cfg.Config)
+	}
+	if c.dialTimeout > 0 {
+		cfg.DialFunc = timeoutDialFunc(cfg.DialFunc, c.dialTimeout)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,141 @@
+package stratus
+
+import (
+	"errors"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
+	"gorm.io/plugin/prometheus"
+)
+
+// WithTracing registers the OpenTelemetry tracing plugin so that every query
+// emits a span with its statement and duration. Spans are created with the
+// global tracer provider, so `otel.SetTracerProvider` has to be called before
+// connecting.
+func WithTracing() ConfigOption {
+	return func(c *config) error {
+		c.plugins = append(c.plugins, tracing.NewPlugin())
+		return nil
+	}
+}
+
+// WithPrometheus registers the GORM Prometheus plugin, which exports the
+// `sql.DBStats` of the connection pool (open, idle and in-use connections,
+// wait count, etc.) as `gorm_dbstats_*` gauges refreshed every refresh. The
+// gauges are registered with the default Prometheus registry; no HTTP server
+// is started, so expose them through the existing `/metrics` handler. The
+// refresh interval is rounded down to the second and defaults to 15 seconds
+// when below one. The gauges follow the connection when it is reopened, e.g.
+// by `Reconnect`, and are unregistered once it is closed.
+func WithPrometheus(refresh time.Duration) ConfigOption {
+	return func(c *config) error {
+		c.plugins = append(c.plugins, prometheus.New(prometheus.Config{
+			RefreshInterval: uint32(refresh / time.Second),
+			StartServer:     false,
+		}))
+		return nil
+	}
+}
+
+// prometheusCollectors returns the gauges of the Prometheus plugin of c, once
+// the plugin has been initialized.
+func (c *config) prometheusCollectors() []promclient.Collector {
+	var collectors []promclient.Collector
+	for _, plugin := range c.plugins {
+		if p, ok := plugin.(*prometheus.Prometheus); ok && p.DBStats != nil {
+			collectors = append(collectors, p.DBStats.Collectors()...)
+		}
+	}
+
+	return collectors
+}
+
+// registerMetrics registers the gauges of the Prometheus plugin of c. The
+// plugin registers them itself, but silently fails to while the gauges of
+// the connection being replaced are still registered, as they share their
+// names.
+func (c *config) registerMetrics() {
+	for _, collector := range c.prometheusCollectors() {
+		_ = promclient.Register(collector)
+	}
+}
+
+// unregisterMetrics unregisters the gauges of the Prometheus plugin of c, so
+// that they stop reporting the pool of a closed connection. The registry only
+// tells collectors apart by their metrics, so gauges of another connection
+// registered under the same names are checked for and left alone.
+func (c *config) unregisterMetrics() {
+	for _, collector := range c.prometheusCollectors() {
+		var are promclient.AlreadyRegisteredError
+		if err := promclient.Register(collector); err == nil || errors.As(err, &are) && are.ExistingCollector == collector {
+			promclient.Unregister(collector)
+		}
+	}
+}
+
+// statementCallbacks are callbacks registered around every statement
+// executed through GORM by `registerStatementCallbacks`.
+type statementCallbacks struct {
+	// before runs ahead of every other callback of the create, query, update,
+	// delete, row and raw processors. With beforeStatement, it runs later
+	// instead: once dbresolver picked the connection pool, right before the
+	// statement starts, i.e. ahead of the default transaction of writes.
+	before          func(*gorm.DB)
+	beforeStatement bool
+
+	// after runs behind every other callback of the same processors, except
+	// for row, which runs afterRow instead, if any: rows read through `Row`
+	// and `Rows` are only scanned once the callbacks are done.
+	after    func(*gorm.DB)
+	afterRow func(*gorm.DB)
+}
+
+// registerStatementCallbacks registers sc on db, under the names
+// `stratus:before_<name>` and `stratus:after_<name>`. gorm doesn't export its
+// processor type, hence the method values.
+func registerStatementCallbacks(db *gorm.DB, name string, sc statementCallbacks) error {
+	cb := db.Callback()
+	befores := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().Before("*").Register,
+		cb.Query().Before("*").Register,
+		cb.Update().Before("*").Register,
+		cb.Delete().Before("*").Register,
+		cb.Row().Before("*").Register,
+		cb.Raw().Before("*").Register,
+	}
+	if sc.beforeStatement {
+		befores = []func(name string, fn func(*gorm.DB)) error{
+			cb.Create().After("gorm:db_resolver").Before("gorm:begin_transaction").Register,
+			cb.Query().After("gorm:db_resolver").Before("gorm:query").Register,
+			cb.Update().After("gorm:db_resolver").Before("gorm:begin_transaction").Register,
+			cb.Delete().After("gorm:db_resolver").Before("gorm:begin_transaction").Register,
+			cb.Row().After("gorm:db_resolver").Before("gorm:row").Register,
+			cb.Raw().After("gorm:db_resolver").Before("gorm:raw").Register,
+		}
+	}
+	afters := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().After("*").Register,
+		cb.Query().After("*").Register,
+		cb.Update().After("*").Register,
+		cb.Delete().After("*").Register,
+		cb.Raw().After("*").Register,
+	}
+
+	for _, register := range befores {
+		if err := register("stratus:before_"+name, sc.before); err != nil {
+			return err
+		}
+	}
+	for _, register := range afters {
+		if err := register("stratus:after_"+name, sc.after); err != nil {
+			return err
+		}
+	}
+	if sc.afterRow != nil {
+		return cb.Row().After("*").Register("stratus:after_"+name, sc.afterRow)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package stratus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"gorm.io/plugin/opentelemetry/tracing"
+	"gorm.io/plugin/prometheus"
+)
+
+func TestWithTracing(t *testing.T) {
+	db := connectSQLite(t, WithTracing())
+
+	name := tracing.NewPlugin().Name()
+	if _, ok := db.Config.Plugins[name]; !ok {
+		t.Errorf("Plugins = %v, want %s registered", db.Config.Plugins, name)
+	}
+}
+
+// gatheredMaxOpen returns the `gorm_dbstats_max_open_connections` gauge of the
+// default registry, reporting false if it isn't registered.
+func gatheredMaxOpen(t *testing.T) (float64, bool) {
+	t.Helper()
+
+	families, err := promclient.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "gorm_dbstats_max_open_connections" {
+			return family.GetMetric()[0].GetGauge().GetValue(), true
+		}
+	}
+
+	return 0, false
+}
+
+// refreshMetrics updates the gauges of the Prometheus plugin of the primary
+// database right away, as the plugin does every refresh interval.
+func refreshMetrics(t *testing.T) {
+	t.Helper()
+
+	inst, ok := instances[DefaultName]
+	if !ok {
+		t.Fatal("the primary database isn't connected")
+	}
+	for _, plugin := range inst.c.plugins {
+		if p, ok := plugin.(*prometheus.Prometheus); ok {
+			sdb, err := inst.db.DB()
+			if err != nil {
+				t.Fatalf("DB() error = %v", err)
+			}
+			p.DBStats.Set(sdb.Stats())
+		}
+	}
+}
+
+func TestWithPrometheusReconnect(t *testing.T) {
+	connectSQLite(t, WithPrometheus(time.Hour), WithMaxConnections(7))
+	refreshMetrics(t)
+	if got, _ := gatheredMaxOpen(t); got != 7 {
+		t.Fatalf("max open connections gauge = %v, want 7", got)
+	}
+
+	if err := Reconnect(context.Background()); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+	sdb, err := GetInstance().DB()
+	if err != nil {
+		t.Fatalf("DB() error = %v", err)
+	}
+	sdb.SetMaxOpenConns(3)
+	refreshMetrics(t)
+	if got, _ := gatheredMaxOpen(t); got != 3 {
+		t.Errorf("max open connections gauge = %v after Reconnect, want the new pool's 3", got)
+	}
+
+	if err := Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got, ok := gatheredMaxOpen(t); ok {
+		t.Errorf("max open connections gauge = %v after Close, want it unregistered", got)
+	}
+
+	// a new connection registers its own gauges again
+	connectSQLite(t, WithPrometheus(time.Hour), WithMaxConnections(5))
+	refreshMetrics(t)
+	if got, _ := gatheredMaxOpen(t); got != 5 {
+		t.Errorf("max open connections gauge = %v after Close and Connect, want 5", got)
+	}
+}
@@ -0,0 +1,35 @@
+package stratus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/funayman/stratus"
+	"github.com/funayman/stratus/stratustest"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/testcontainers/testcontainers-go"
+	"gorm.io/gorm"
+)
+
+func TestWithReadOnlyRejectsWrites(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	db := stratustest.NewPostgres(t, stratus.WithReadOnly())
+
+	// a transaction can still opt out before its first statement, which is
+	// the only way to create the table on a read-only connection
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET TRANSACTION READ WRITE").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE TABLE widgets (id integer)").Error
+	})
+	if err != nil {
+		t.Fatalf("CREATE TABLE error = %v", err)
+	}
+
+	err = db.Exec("INSERT INTO widgets (id) VALUES (1)").Error
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "25006" {
+		t.Errorf("INSERT error = %v, want read_only_sql_transaction (25006)", err)
+	}
+}
@@ -0,0 +1,63 @@
+package stratus
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Session returns the primary database bound to ctx, so that cancellation and
+// tracing propagate to every statement ran on it. It is shorthand for
+// `GetInstance().WithContext(ctx)`, and panics in the same way if the database
+// has not been initialized. The returned session is a new GORM session and is
+// safe for concurrent use.
+func Session(ctx context.Context) *gorm.DB {
+	return GetInstance().WithContext(ctx)
+}
+
+// Transaction runs fn inside a transaction on the primary database, passing
+// ctx through to every statement. The transaction is committed if fn returns
+// nil, and rolled back if fn returns an error or panics, in which case the
+// panic is propagated once the rollback is done. Returns `ErrNotInitialized`
+// if `stratus.Connect` was never called.
+func Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	db, err := lookup(DefaultName)
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(fn)
+}
+
+// Exec runs sql with values on the primary database, passing ctx through. It
+// is intended for one-off maintenance statements, such as `VACUUM` or
+// `REFRESH MATERIALIZED VIEW`. Returns `ErrNotInitialized` if
+// `stratus.Connect` was never called.
+func Exec(ctx context.Context, sql string, values ...interface{}) error {
+	db, err := lookup(DefaultName)
+	if err != nil {
+		return err
+	}
+
+	if err := db.WithContext(ctx).Exec(sql, values...).Error; err != nil {
+		return fmt.Errorf("unable to exec sql: %w", err)
+	}
+	return nil
+}
+
+// Raw runs the sql query with values on the primary database, passing ctx
+// through, and scans the result into dest, which can be a pointer to a
+// scalar, a struct or a slice of either. Returns `ErrNotInitialized` if
+// `stratus.Connect` was never called.
+func Raw(ctx context.Context, dest interface{}, sql string, values ...interface{}) error {
+	db, err := lookup(DefaultName)
+	if err != nil {
+		return err
+	}
+
+	if err := db.WithContext(ctx).Raw(sql, values...).Scan(dest).Error; err != nil {
+		return fmt.Errorf("unable to run raw sql: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// WithReplicas registers read replicas next to the primary database using
+// GORM's dbresolver plugin. Reads are routed to the replicas while writes and
+// transactions keep going to the primary. Replicas are opened with the same
+// driver and configuration as the primary, and DBOptions such as
+// `WithMaxConnections` are applied to every replica as well.
+func WithReplicas(dsns ...string) ConfigOption {
+	return func(c *config) error {
+		for _, dsn := range dsns {
+			c.replicas = append(c.replicas, ReplicaConfig{DSN: dsn, Weight: 1})
+		}
+		return nil
+	}
+}
+
+// ReplicaConfig is a read replica registered by `WithWeightedReplicas`.
+type ReplicaConfig struct {
+	// DSN is the data source name of the replica.
+	DSN string
+	// Weight is the share of the reads routed to the replica, relative to the
+	// weights of the other replicas. It must be positive.
+	Weight int
+}
+
+// WithWeightedReplicas behaves like `WithReplicas`, but distributes reads
+// across the replicas in proportion to their weights rather than evenly,
+// e.g. a replica of weight 3 receives three times the reads of one of weight
+// 1, for replicas of different sizes. Replicas registered by `WithReplicas`
+// have a weight of 1, and both options can be combined.
+func WithWeightedReplicas(replicas []ReplicaConfig) ConfigOption {
+	return func(c *config) error {
+		for _, replica := range replicas {
+			if replica.Weight <= 0 {
+				return fmt.Errorf("replica weight must be positive, got %d", replica.Weight)
+			}
+		}
+		c.replicas = append(c.replicas, replicas...)
+		return nil
+	}
+}
+
+// weightedPolicy is a dbresolver policy picking replicas at random in
+// proportion to their weights, which are given in the order the replicas were
+// registered in.
+type weightedPolicy struct {
+	weights []int
+	total   int
+}
+
+// newWeightedPolicy returns the policy distributing reads across replicas,
+// or nil, i.e. dbresolver's evenly distributing default, if all of them have
+// the same weight.
+func newWeightedPolicy(replicas []ReplicaConfig) dbresolver.Policy {
+	p := &weightedPolicy{weights: make([]int, 0, len(replicas))}
+	uniform := true
+	for _, replica := range replicas {
+		if replica.Weight != replicas[0].Weight {
+			uniform = false
+		}
+		p.weights = append(p.weights, replica.Weight)
+		p.total += replica.Weight
+	}
+	if uniform {
+		return nil
+	}
+
+	return p
+}
+
+// Resolve implements `dbresolver.Policy`.
+func (p *weightedPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	// the replicas are always resolved in registration order, this only
+	// guards against the weights getting out of sync with them.
+	if len(pools) != len(p.weights) {
+		return dbresolver.RandomPolicy{}.Resolve(pools)
+	}
+
+	n := rand.Intn(p.total)
+	for i, weight := range p.weights {
+		if n < weight {
+			return pools[i]
+		}
+		n -= weight
+	}
+
+	return pools[len(pools)-1]
+}
+
+// useReplicas registers the configured replicas on db.
+func useReplicas(ctx context.Context, db *gorm.DB, driver string, c *config) error {
+	replicas := make([]gorm.Dialector, 0, len(c.replicas))
+	for _, replica := range c.replicas {
+		dsn := replica.DSN
+		if isPostgres(driver) {
+			if _, params := popDSNParams(dsn, isPoolParam); len(params) > 0 {
+				return phaseError(PhaseOptions, errors.New("pool parameters are not supported in replica dsns, replicas share the pool settings of the primary"))
+			}
+		}
+		dialector, err := c.dialector(driver, dsn)
+		if err != nil {
+			return phaseError(PhaseOptions, err)
+		}
+		replicas = append(replicas, dialector)
+	}
+
+	primary, err := db.DB()
+	if err != nil {
+		return phaseError(PhaseGormOpen, fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err))
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   newWeightedPolicy(c.replicas),
+	})
+	if err := db.Use(resolver); err != nil {
+		return phaseError(PhaseGormOpen, fmt.Errorf("unable to register replicas: %w", err))
+	}
+
+	err = resolver.Call(func(pool gorm.ConnPool) error {
+		sdb, ok := pool.(*sql.DB)
+		if !ok || sdb == primary {
+			return nil
+		}
+
+		if err := c.applyDBOptions(sdb); err != nil {
+			return phaseError(PhaseOptions, err)
+		}
+		if c.pingOnConnect {
+			if err := sdb.PingContext(ctx); err != nil {
+				return phaseError(PhasePing, fmt.Errorf("unable to ping replica: %w", err))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// closeReplicas closes the replicas registered on db, if any.
+func closeReplicas(db *gorm.DB) error {
+	resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver)
+	if !ok {
+		return nil
+	}
+
+	primary, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("unable to fetch *sql.DB from *gorm.DB: %w", err)
+	}
+
+	return resolver.Call(func(pool gorm.ConnPool) error {
+		sdb, ok := pool.(*sql.DB)
+		if !ok || sdb == primary {
+			return nil
+		}
+		if err := sdb.Close(); err != nil {
+			return fmt.Errorf("unable to close replica: %w", err)
+		}
+
+		return nil
+	})
+}
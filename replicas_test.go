@@ -0,0 +1,49 @@
+package stratus
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// fakeConnPool stands in for a replica pool, only compared by identity.
+type fakeConnPool struct {
+	gorm.ConnPool
+	name string
+}
+
+func TestWithReplicas(t *testing.T) {
+	db := connectSQLite(t, WithReplicas(sqliteDSN()))
+
+	if _, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver); !ok {
+		t.Errorf("Plugins = %v, want the dbresolver plugin registered", db.Config.Plugins)
+	}
+}
+
+func TestWeightedPolicy(t *testing.T) {
+	if p := newWeightedPolicy([]ReplicaConfig{{DSN: "a", Weight: 2}, {DSN: "b", Weight: 2}}); p != nil {
+		t.Errorf("newWeightedPolicy() with uniform weights = %v, want nil", p)
+	}
+
+	p := newWeightedPolicy([]ReplicaConfig{{DSN: "a", Weight: 1}, {DSN: "b", Weight: 3}})
+	if p == nil {
+		t.Fatal("newWeightedPolicy() = nil, want a weighted policy")
+	}
+
+	light, heavy := &fakeConnPool{name: "a"}, &fakeConnPool{name: "b"}
+	pools := []gorm.ConnPool{light, heavy}
+
+	const n = 4000
+	counts := map[gorm.ConnPool]int{}
+	for i := 0; i < n; i++ {
+		counts[p.Resolve(pools)]++
+	}
+	if len(counts) != 2 {
+		t.Fatalf("Resolve() returned %d distinct pools, want the 2 replicas", len(counts))
+	}
+	// a quarter is expected, the bounds are more than 10 standard deviations off
+	if got := counts[light]; got < n/4-300 || got > n/4+300 {
+		t.Errorf("Resolve() picked the replica of weight 1 %d times out of %d, want about %d", got, n, n/4)
+	}
+}
@@ -0,0 +1,145 @@
+package stratus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFound is returned by `Repo` methods when no record matches. Use
+// `errors.Is` to check for it.
+var ErrNotFound = errors.New("record not found")
+
+// Repo provides typed CRUD operations on the records of the model T, passing
+// the context through to every statement.
+type Repo[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepo returns a `Repo` for the model T on top of db. With a nil db, the
+// repo uses the primary database, looked up on every call, so it can be
+// created before `stratus.Connect` is called; its methods then return
+// `ErrNotInitialized` until it is.
+func NewRepo[T any](db *gorm.DB) *Repo[T] {
+	return &Repo[T]{db: db}
+}
+
+// Create inserts v.
+func (r *Repo[T]) Create(ctx context.Context, v *T) error {
+	db, err := r.session(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Create(v).Error; err != nil {
+		return fmt.Errorf("unable to create %T: %w", v, err)
+	}
+	return nil
+}
+
+// FindByID returns the record whose primary key is id, or `ErrNotFound`.
+func (r *Repo[T]) FindByID(ctx context.Context, id any) (*T, error) {
+	db, err := r.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	v := new(T)
+	if err := db.First(v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to find %T: %w", v, err)
+	}
+	return v, nil
+}
+
+// Update saves every field of v, zero values included. As with GORM's
+// `Save`, v is inserted if its primary key is the zero value.
+func (r *Repo[T]) Update(ctx context.Context, v *T) error {
+	db, err := r.session(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Save(v).Error; err != nil {
+		return fmt.Errorf("unable to update %T: %w", v, err)
+	}
+	return nil
+}
+
+// Delete deletes the record whose primary key is id, or returns
+// `ErrNotFound` if there is none. Models with a `gorm.DeletedAt` field are
+// soft deleted.
+func (r *Repo[T]) Delete(ctx context.Context, id any) error {
+	db, err := r.byID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	res := db.Delete(new(T))
+	if res.Error != nil {
+		return fmt.Errorf("unable to delete %T: %w", new(T), res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List returns the records matching scopes, such as
+// `func(db *gorm.DB) *gorm.DB { return db.Where("active").Limit(10) }`, or
+// every record without any.
+func (r *Repo[T]) List(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) ([]T, error) {
+	db, err := r.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var vs []T
+	if err := db.Scopes(scopes...).Find(&vs).Error; err != nil {
+		return nil, fmt.Errorf("unable to list %T: %w", vs, err)
+	}
+	return vs, nil
+}
+
+// session returns the database of r bound to ctx.
+func (r *Repo[T]) session(ctx context.Context) (*gorm.DB, error) {
+	db := r.db
+	if db == nil {
+		var err error
+		if db, err = lookup(DefaultName); err != nil {
+			return nil, err
+		}
+	}
+
+	return db.WithContext(ctx), nil
+}
+
+// byID returns the database of r bound to ctx, and restricted to the record
+// whose primary key is id. The primary key column is looked up on the model
+// rather than passing id to `First`, which would take a string id for a SQL
+// condition.
+func (r *Repo[T]) byID(ctx context.Context, id any) (*gorm.DB, error) {
+	db, err := r.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("unable to parse %T: %w", new(T), err)
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("%T has no primary key", new(T))
+	}
+
+	return db.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName},
+		Value:  id,
+	}), nil
+}
@@ -0,0 +1,52 @@
+package stratus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConnectWithRetry calls `ConnectContext` up to `attempts` times, waiting
+// `backoff` before the second attempt and doubling the wait after every
+// failure. This is useful during rolling deploys where the database may not be
+// ready for the first few seconds. Both the attempts and the wait between them
+// are cut short if ctx is done, in which case the context error is returned.
+// If all attempts fail, the error from the last attempt is returned.
+//
+// Errors that cannot be fixed by trying again, such as an unsupported driver,
+// an invalid option or the database already being connected, are returned
+// immediately.
+func ConnectWithRetry(ctx context.Context, attempts int, backoff time.Duration, driver, dsn string, opts ...Option) (*gorm.DB, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("connect cancelled after %d attempt(s), last error %v: %w", i, err, ctx.Err())
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+
+		var db *gorm.DB
+		db, err = ConnectContext(ctx, driver, dsn, opts...)
+		if err == nil || errors.Is(err, ErrAlreadyConnected) || errors.Is(err, ErrUnsupportedDriver) {
+			return db, err
+		}
+		var cerr *ConnectError
+		if errors.As(err, &cerr) && cerr.Phase == PhaseOptions {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
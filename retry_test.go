@@ -0,0 +1,22 @@
+package stratus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectWithRetryGivesUpOnOptions(t *testing.T) {
+	t.Cleanup(Reset)
+
+	start := time.Now()
+	_, err := ConnectWithRetry(context.Background(), 4, 100*time.Millisecond, "sqlite", ":memory:", WithReadOnly())
+	var cerr *ConnectError
+	if !errors.As(err, &cerr) || cerr.Phase != PhaseOptions {
+		t.Fatalf("ConnectWithRetry() error = %v, want a PhaseOptions ConnectError", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("ConnectWithRetry() took %s, want it to give up without backing off", elapsed)
+	}
+}
@@ -0,0 +1,62 @@
+package stratus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"gorm.io/gorm"
+)
+
+// ConnectFromSecretManager calls `ConnectContext` with the DSN stored in the
+// GCP Secret Manager secret name, such as `projects/p/secrets/dsn`, whose
+// latest version is used, or a specific version of it, such as
+// `projects/p/secrets/dsn/versions/3`. The secret is accessed with the
+// application default credentials. The DSN is scrubbed from the returned
+// errors, on top of the password redaction `Connect` already does.
+func ConnectFromSecretManager(ctx context.Context, driver, name string, opts ...Option) (*gorm.DB, error) {
+	dsn, err := accessSecret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := ConnectContext(ctx, driver, dsn, opts...)
+	return db, scrubError(err, dsn)
+}
+
+// accessSecret returns the payload of the Secret Manager secret name,
+// defaulting to its latest version.
+func accessSecret(ctx context.Context, name string) (string, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to create secret manager client: %w", err)
+	}
+	defer client.Close()
+
+	resp, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", fmt.Errorf("unable to access secret %s: %w", name, err)
+	}
+
+	dsn := strings.TrimSpace(string(resp.GetPayload().GetData()))
+	if dsn == "" {
+		return "", fmt.Errorf("secret %s is empty", name)
+	}
+
+	return dsn, nil
+}
+
+// scrubError wraps err in a `redactedError` if its message contains secret.
+func scrubError(err error, secret string) error {
+	if err == nil || !strings.Contains(err.Error(), secret) {
+		return err
+	}
+
+	return &redactedError{err: err, msg: strings.ReplaceAll(err.Error(), secret, redacted)}
+}
@@ -0,0 +1,169 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// WithStartupLogging logs the configuration a connection was actually opened
+// with to l, as a single info line: the driver, the DSN with its password
+// redacted, the pool limits, the GORM log level and the options that changed
+// a default. The idle connection limit is the one set by the options of this
+// package, custom DB options calling `SetMaxIdleConns` themselves aren't seen,
+// and it is left out for connections opened by the caller unless an option
+// sets it. The line is written once the DB options are applied, but before
+// the connection is verified, so it's there for deployments that fail to
+// connect too.
+func WithStartupLogging(l *slog.Logger) ConfigOption {
+	return func(c *config) error {
+		c.startupLogger = l
+		return nil
+	}
+}
+
+// logStartup writes the startup line of `WithStartupLogging`, if enabled.
+func (c *config) logStartup(ctx context.Context, dsn string, gdb *gorm.DB, sdb *sql.DB) {
+	if c.startupLogger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("driver", c.driver)}
+	if dsn != "" {
+		attrs = append(attrs, slog.String("dsn", redactDSN(dsn)))
+	}
+	maxOpen := sdb.Stats().MaxOpenConnections
+	attrs = append(attrs, slog.Int("max_open_conns", maxOpen))
+	if c.maxIdleConnsKnown {
+		maxIdle := c.maxIdleConns
+		if maxOpen > 0 && maxIdle > maxOpen {
+			// `database/sql` lowers the idle limit to the open one
+			maxIdle = maxOpen
+		}
+		attrs = append(attrs, slog.Int("max_idle_conns", maxIdle))
+	}
+	attrs = append(attrs, slog.Any("options", c.appliedOptions(gdb.Config)))
+
+	c.startupLogger.LogAttrs(ctx, slog.LevelInfo, "stratus: opening database", attrs...)
+}
+
+// appliedOptions groups the log level along with the settings of c and cfg
+// that differ from their defaults.
+func (c *config) appliedOptions(cfg *gorm.Config) slog.Value {
+	var attrs []slog.Attr
+	flag := func(key string, set bool) {
+		if set {
+			attrs = append(attrs, slog.Bool(key, true))
+		}
+	}
+	str := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+
+	attrs = append(attrs, slog.String("log_level", logLevelName(c)))
+	if !c.pingOnConnect {
+		attrs = append(attrs, slog.Bool("ping_on_connect", false))
+	}
+	flag("tls", c.tlsConfig != nil)
+	flag("read_only", c.readOnly)
+	flag("simple_protocol", c.simpleProtocol)
+	flag("pgbouncer", c.pgBouncer)
+	flag("prepare_stmt", cfg.PrepareStmt)
+	flag("skip_default_transaction", cfg.SkipDefaultTransaction)
+	flag("translate_error", cfg.TranslateError)
+	flag("disable_nested_transaction", cfg.DisableNestedTransaction)
+	flag("dry_run", cfg.DryRun)
+	flag("query_fields", cfg.QueryFields)
+	flag("full_save_associations", cfg.FullSaveAssociations)
+	flag("disable_foreign_key_constraint_when_migrating", cfg.DisableForeignKeyConstraintWhenMigrating)
+	flag("now_func", c.gorm.NowFunc != nil)
+	if c.gorm.NamingStrategy != nil {
+		// an explicit strategy takes precedence over `WithSingularTable`
+		attrs = append(attrs, slog.String("naming_strategy", "custom"))
+	} else {
+		flag("singular_table", c.singularTable)
+	}
+	if cfg.CreateBatchSize > 0 {
+		attrs = append(attrs, slog.Int("create_batch_size", cfg.CreateBatchSize))
+	}
+	if c.defaultStringSize > 0 {
+		attrs = append(attrs, slog.Uint64("default_string_size", uint64(c.defaultStringSize)))
+	}
+	if c.logConfig.SlowThreshold != time.Second {
+		attrs = append(attrs, slog.Duration("slow_threshold", c.logConfig.SlowThreshold))
+	}
+	flag("colorful", c.logConfig.Colorful)
+	if len(c.gormFuncs) > 0 {
+		attrs = append(attrs, slog.Int("gorm_config_funcs", len(c.gormFuncs)))
+	}
+	str("application_name", c.applicationName)
+	str("time_zone", c.timeZone)
+	str("schema", c.schema)
+	if c.statementTimeout > 0 {
+		attrs = append(attrs, slog.Duration("statement_timeout", c.statementTimeout))
+	}
+	if c.dialTimeout > 0 {
+		attrs = append(attrs, slog.Duration("dial_timeout", c.dialTimeout))
+	}
+	if c.healthInterval > 0 {
+		attrs = append(attrs, slog.Duration("health_interval", c.healthInterval))
+	}
+	if len(c.connParams) > 0 {
+		// only the names, the values may be credentials
+		names := make([]string, 0, len(c.connParams))
+		for name := range c.connParams {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		attrs = append(attrs, slog.Any("connection_params", names))
+	}
+	if len(c.initSQL) > 0 {
+		attrs = append(attrs, slog.Int("connection_init_sql", len(c.initSQL)))
+	}
+	if len(c.replicas) > 0 {
+		attrs = append(attrs, slog.Int("replicas", len(c.replicas)))
+	}
+	if len(c.plugins) > 0 {
+		names := make([]string, 0, len(c.plugins))
+		for _, plugin := range c.plugins {
+			names = append(names, plugin.Name())
+		}
+		attrs = append(attrs, slog.Any("plugins", names))
+	}
+	if c.driver == "cloudsql-postgres" {
+		attrs = append(attrs, slog.Bool("cloudsql_iam_auth", c.cloudSQLIAMAuth))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// logLevelName names the level of the default GORM logger, or reports that
+// it has been replaced.
+func logLevelName(c *config) string {
+	if c.silent {
+		return "silent"
+	}
+	if c.logger != nil {
+		return "custom"
+	}
+
+	switch c.logConfig.LogLevel {
+	case logger.Silent:
+		return "silent"
+	case logger.Error:
+		return "error"
+	case logger.Warn:
+		return "warn"
+	case logger.Info:
+		return "info"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,141 @@
+package stratus
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// startupLine connects to SQLite with opts and startup logging, and returns
+// the decoded startup line.
+func startupLine(t *testing.T, opts ...Option) map[string]interface{} {
+	t.Helper()
+
+	return logStartupLine(t, func(opts ...Option) error {
+		connectSQLite(t, opts...)
+		return nil
+	}, opts...)
+}
+
+// logStartupLine calls connect with opts and startup logging, and returns the
+// decoded startup line.
+func logStartupLine(t *testing.T, connect func(...Option) error, opts ...Option) map[string]interface{} {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := connect(append(opts, WithStartupLogging(slog.New(slog.NewJSONHandler(&buf, nil))))...); err != nil {
+		t.Fatalf("connect error = %v", err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("startup line %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestWithStartupLoggingPoolLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantOpen float64
+		wantIdle float64
+	}{
+		{"defaults", nil, 0, 2},
+		{"idle", []Option{WithMaxConnections(10), WithMaxIdleConnections(5)}, 10, 5},
+		{"no idle", []Option{WithMaxIdleConnections(0)}, 0, 0},
+		{"pool", []Option{WithPool(PoolConfig{MaxOpen: 8, MaxIdle: 3})}, 8, 3},
+		// database/sql lowers the idle limit to the open one
+		{"capped", []Option{WithMaxIdleConnections(5), WithMaxConnections(1)}, 1, 1},
+	}
+	for _, tt := range tests {
+		line := startupLine(t, tt.opts...)
+		if got := line["max_open_conns"]; got != tt.wantOpen {
+			t.Errorf("%s: max_open_conns = %v, want %v", tt.name, got, tt.wantOpen)
+		}
+		if got := line["max_idle_conns"]; got != tt.wantIdle {
+			t.Errorf("%s: max_idle_conns = %v, want %v", tt.name, got, tt.wantIdle)
+		}
+		Reset()
+	}
+}
+
+func TestWithStartupLoggingOptions(t *testing.T) {
+	line := startupLine(t,
+		WithSingularTable(),
+		WithNowFunc(time.Now),
+		WithCreateBatchSize(100),
+		WithQueryFields(),
+		WithFullSaveAssociations(),
+		WithDisableForeignKeyConstraintWhenMigrating(),
+		WithDefaultStringSize(191),
+		WithSlowThreshold(250*time.Millisecond),
+		WithColorfulLogger(true),
+	)
+	options, ok := line["options"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("options = %v, want a group", line["options"])
+	}
+
+	for key, want := range map[string]interface{}{
+		"singular_table":         true,
+		"now_func":               true,
+		"create_batch_size":      float64(100),
+		"query_fields":           true,
+		"full_save_associations": true,
+		"disable_foreign_key_constraint_when_migrating": true,
+		"default_string_size":                           float64(191),
+		"slow_threshold":                                float64(250 * time.Millisecond),
+		"colorful":                                      true,
+	} {
+		if got := options[key]; got != want {
+			t.Errorf("options[%s] = %v, want %v", key, got, want)
+		}
+	}
+	Reset()
+
+	line = startupLine(t, WithSingularTable(), WithNamingStrategy(schema.NamingStrategy{}))
+	options, _ = line["options"].(map[string]interface{})
+	if got := options["naming_strategy"]; got != "custom" {
+		t.Errorf("options[naming_strategy] = %v, want custom", got)
+	}
+	if _, ok := options["singular_table"]; ok {
+		t.Error("options[singular_table] logged along with an explicit naming strategy, which takes precedence")
+	}
+}
+
+func TestWithStartupLoggingExternalPool(t *testing.T) {
+	t.Cleanup(Reset)
+	connectWithDB := func(opts ...Option) error {
+		_, err := ConnectWithDB("sqlite", openSQLite(t), opts...)
+		return err
+	}
+
+	// the caller may have set any limit on its own connection
+	line := logStartupLine(t, connectWithDB)
+	if got, ok := line["max_idle_conns"]; ok {
+		t.Errorf("max_idle_conns = %v for a connection opened by the caller, want it left out", got)
+	}
+	Reset()
+
+	line = logStartupLine(t, connectWithDB, WithMaxIdleConnections(3))
+	if got := line["max_idle_conns"]; got != float64(3) {
+		t.Errorf("max_idle_conns = %v, want 3", got)
+	}
+}
+
+func TestSetMaxIdleConnsOutsideConnect(t *testing.T) {
+	if err := WithMaxIdleConnections(4)(openSQLite(t)); err != nil {
+		t.Fatalf("WithMaxIdleConnections() error = %v", err)
+	}
+
+	idleConnMu.Lock()
+	defer idleConnMu.Unlock()
+	if n := len(idleConnRecorders); n != 0 {
+		t.Errorf("%d idle connection recorder(s) left behind, want none", n)
+	}
+}
@@ -0,0 +1,73 @@
+package stratus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Stats returns the connection pool statistics of the underlying `*sql.DB`,
+// such as the number of open, in-use and idle connections, making it trivial
+// to export them to a metrics backend. Returns `ErrNotInitialized` if
+// `stratus.Connect` was never called.
+func Stats() (sql.DBStats, error) {
+	sdb, err := sqlDB(DefaultName)
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+
+	return sdb.Stats(), nil
+}
+
+// ReaperStats reports how many connections the pool has closed so far for
+// sitting idle longer than `WithConnMaxIdleTime` and for outliving
+// `WithConnMaxLifetime`, respectively. Comparing the two helps tune the pool
+// recycling options. Both are also available through `Stats`.
+func ReaperStats() (idleClosed, lifetimeClosed int64, err error) {
+	stats, err := Stats()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stats.MaxIdleTimeClosed, stats.MaxLifetimeClosed, nil
+}
+
+// Warmup pre-establishes n connections of the primary database, so that the
+// first requests served after startup don't pay for dialing, TLS and
+// authentication. All n connections are acquired at once, then released to
+// the pool; call it right after `Connect`. Released connections are only kept
+// up to the `WithMaxIdleConnections` limit, which defaults to two in
+// `database/sql`, so raise it to at least n. Returns an error if n exceeds
+// the `WithMaxConnections` limit, or if a connection can't be established
+// before ctx is done.
+func Warmup(ctx context.Context, n int) error {
+	sdb, err := sqlDB(DefaultName)
+	if err != nil {
+		return err
+	}
+	if max := sdb.Stats().MaxOpenConnections; max > 0 && n > max {
+		return fmt.Errorf("unable to warm up %d connections, the pool is limited to %d", n, max)
+	}
+
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		conn, err := sdb.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to warm up connection %d of %d: %w", i+1, n, err)
+		}
+		conns = append(conns, conn)
+
+		// connections are established lazily by some drivers
+		if err := conn.PingContext(ctx); err != nil {
+			return fmt.Errorf("unable to warm up connection %d of %d: %w", i+1, n, err)
+		}
+	}
+
+	return nil
+}
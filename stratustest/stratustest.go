@@ -0,0 +1,94 @@
+// Package stratustest provides disposable databases for tests, connected as
+// the primary `stratus` database so that code under test reaches them through
+// `stratus.GetInstance()`. Since stratus holds a single primary database,
+// tests using this package can't run in parallel with each other.
+package stratustest
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/funayman/stratus"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/gorm"
+)
+
+// PostgresImage is the container image started by `NewPostgres`.
+const PostgresImage = "postgres:15-alpine"
+
+// sqliteDatabases counts the databases opened by `NewSQLite`, to give each
+// one a unique name.
+var sqliteDatabases uint64
+
+// NewPostgres starts a disposable Postgres container, which requires a
+// running Docker daemon, and connects to it with `stratus.Connect` and opts.
+// The connection is closed and the container removed when the test ends.
+func NewPostgres(t *testing.T, opts ...stratus.Option) *gorm.DB {
+	t.Helper()
+
+	ctx := context.Background()
+	container, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage(PostgresImage),
+		postgres.WithDatabase("stratus"),
+		postgres.WithUsername("stratus"),
+		postgres.WithPassword("stratus"),
+		testcontainers.WithWaitStrategy(
+			// postgres restarts once the init scripts have run, so it is
+			// only ready the second time around.
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		),
+	)
+	if err != nil {
+		t.Fatalf("unable to start postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Errorf("unable to terminate postgres container: %v", err)
+		}
+	})
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("unable to fetch postgres dsn: %v", err)
+	}
+
+	return connect(t, "postgres", dsn, opts...)
+}
+
+// NewSQLite connects to a fresh in-memory SQLite database with
+// `stratus.Connect` and opts, for tests that don't need a real server. The
+// connection is closed when the test ends, which drops the database.
+func NewSQLite(t *testing.T, opts ...stratus.Option) *gorm.DB {
+	t.Helper()
+
+	// every connection to a plain `:memory:` DSN gets a database of its own,
+	// a named shared cache makes the whole pool see the same one.
+	n := atomic.AddUint64(&sqliteDatabases, 1)
+	dsn := fmt.Sprintf("file:stratustest%d?mode=memory&cache=shared", n)
+
+	return connect(t, "sqlite", dsn, opts...)
+}
+
+// connect connects the primary database and closes it when the test ends.
+func connect(t *testing.T, driver, dsn string, opts ...stratus.Option) *gorm.DB {
+	t.Helper()
+
+	db, err := stratus.Connect(driver, dsn, opts...)
+	if err != nil {
+		t.Fatalf("unable to connect to %s: %v", driver, err)
+	}
+	t.Cleanup(func() {
+		if err := stratus.Close(); err != nil {
+			t.Errorf("unable to close %s: %v", driver, err)
+		}
+	})
+
+	return db
+}
@@ -0,0 +1,72 @@
+package stratus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// queryTimeoutCancelKey is the statement instance key the cancel func of a
+// default query timeout is stored under.
+const queryTimeoutCancelKey = "stratus:query_timeout_cancel"
+
+// WithDefaultQueryTimeout bounds every statement ran through GORM whose
+// context has no deadline by d, so that no statement runs unbounded, e.g.
+// when issued without `WithContext`. Contexts that already carry a deadline
+// are left untouched, even when it is further off than d. For `Row` and
+// `Rows`, the timeout also covers scanning the rows, as it has to outlive the
+// query.
+func WithDefaultQueryTimeout(d time.Duration) ConfigOption {
+	return func(c *config) error {
+		if d <= 0 {
+			return fmt.Errorf("default query timeout must be positive, got %s", d)
+		}
+		c.plugins = append(c.plugins, &queryTimeoutPlugin{timeout: d})
+		return nil
+	}
+}
+
+// queryTimeoutPlugin registers the GORM callbacks applying the default query
+// timeout.
+type queryTimeoutPlugin struct {
+	timeout time.Duration
+}
+
+// Name implements `gorm.Plugin`.
+func (p *queryTimeoutPlugin) Name() string {
+	return "stratus:query_timeout"
+}
+
+// Initialize implements `gorm.Plugin`.
+func (p *queryTimeoutPlugin) Initialize(db *gorm.DB) error {
+	// the context of `Row` and `Rows` is left to expire on its own, as it has
+	// to outlive the callbacks.
+	return registerStatementCallbacks(db, "query_timeout", statementCallbacks{
+		before: p.before,
+		after:  p.after,
+	})
+}
+
+func (p *queryTimeoutPlugin) before(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	db.Statement.Context = ctx
+	db.InstanceSet(queryTimeoutCancelKey, cancel)
+}
+
+func (p *queryTimeoutPlugin) after(db *gorm.DB) {
+	if v, ok := db.InstanceGet(queryTimeoutCancelKey); ok {
+		if cancel, ok := v.(context.CancelFunc); ok {
+			cancel()
+		}
+	}
+}
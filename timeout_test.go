@@ -0,0 +1,48 @@
+package stratus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithDefaultQueryTimeout(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	db := connectSQLite(t,
+		WithDefaultQueryTimeout(time.Minute),
+		WithQueryHook(func(ctx context.Context, _ string, _ time.Duration, _ int64, _ error) {
+			deadline, hasDeadline = ctx.Deadline()
+		}),
+	)
+
+	start := time.Now()
+	if err := db.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("SELECT 1 error = %v", err)
+	}
+	if !hasDeadline {
+		t.Fatal("statement context has no deadline, want the default query timeout")
+	}
+	if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("deadline = %s, want a minute after the statement started at %s", deadline, start)
+	}
+
+	// a deadline of the caller is kept, even when further off than the timeout
+	want := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+	if err := db.WithContext(ctx).Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("SELECT 1 error = %v", err)
+	}
+	if !hasDeadline || !deadline.Equal(want) {
+		t.Errorf("deadline = %s (set %t), want the caller's %s", deadline, hasDeadline, want)
+	}
+}
+
+func TestWithDefaultQueryTimeoutInvalid(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := newConfig([]Option{WithDefaultQueryTimeout(d)}); err == nil {
+			t.Errorf("WithDefaultQueryTimeout(%s) succeeded, want an error", d)
+		}
+	}
+}
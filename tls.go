@@ -0,0 +1,70 @@
+package stratus
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithTLSConfig sets the TLS configuration used by `postgres` and `pgx`
+// connections, e.g. to present a client certificate to a managed provider
+// requiring mutual TLS. cfg is handed to the pgx connection config parsed
+// from the DSN, replacing whatever TLS settings the `sslmode` DSN parameter
+// would produce; the fallbacks of `sslmode=allow` and `sslmode=prefer` to a
+// plaintext connection are dropped as well, so every connection uses cfg.
+// Multi-host DSNs, e.g. `host=a,b`, keep failing over from one host to the
+// next, each of them dialed with cfg. Server verification is up to cfg, set
+// its `RootCAs` for the equivalent of `sslmode=verify-full`; when its
+// `ServerName` is left empty, it defaults to the host being dialed. Unix
+// socket hosts are dialed without TLS, as libpq does.
+//
+// Cloud SQL connections are encrypted by the connector, which ignores this
+// option.
+func WithTLSConfig(cfg *tls.Config) ConfigOption {
+	return func(c *config) error {
+		c.tlsConfig = cfg
+		return nil
+	}
+}
+
+// applyTLSConfig replaces the TLS settings of cfg, and of each fallback host,
+// with c.tlsConfig. pgconn represents both the plaintext fallbacks of
+// `sslmode` and the further hosts of a multi-host DSN as fallbacks, the
+// former are dropped while each of the latter is kept once.
+func (c *config) applyTLSConfig(cfg *pgconn.Config) {
+	cfg.TLSConfig = c.hostTLSConfig(cfg.Host, cfg.Port)
+
+	fallbacks := cfg.Fallbacks
+	cfg.Fallbacks = nil
+	seen := map[string]bool{net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))): true}
+	for _, fallback := range fallbacks {
+		addr := net.JoinHostPort(fallback.Host, strconv.Itoa(int(fallback.Port)))
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+
+		cfg.Fallbacks = append(cfg.Fallbacks, &pgconn.FallbackConfig{
+			Host:      fallback.Host,
+			Port:      fallback.Port,
+			TLSConfig: c.hostTLSConfig(fallback.Host, fallback.Port),
+		})
+	}
+}
+
+// hostTLSConfig returns the TLS configuration for dialing host, or nil for a
+// unix socket.
+func (c *config) hostTLSConfig(host string, port uint16) *tls.Config {
+	if network, _ := pgconn.NetworkAddress(host, port); network == "unix" {
+		return nil
+	}
+
+	cfg := c.tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+
+	return cfg
+}
@@ -0,0 +1,44 @@
+package stratus
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestWithTLSConfigFallbacks(t *testing.T) {
+	c, err := newConfig([]Option{WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	// sslmode=prefer adds a plaintext fallback for every host
+	cfg, err := c.pgxConfig("host=db-a,db-b,/var/run/postgresql port=5432 user=app sslmode=prefer")
+	if err != nil {
+		t.Fatalf("pgxConfig() error = %v", err)
+	}
+
+	if cfg.Host != "db-a" || cfg.TLSConfig == nil || cfg.TLSConfig.ServerName != "db-a" {
+		t.Errorf("primary = %s with TLS %+v, want db-a with ServerName db-a", cfg.Host, cfg.TLSConfig)
+	}
+	if len(cfg.Fallbacks) != 2 {
+		t.Fatalf("len(Fallbacks) = %d, want 2", len(cfg.Fallbacks))
+	}
+	if fb := cfg.Fallbacks[0]; fb.Host != "db-b" || fb.TLSConfig == nil || fb.TLSConfig.ServerName != "db-b" {
+		t.Errorf("Fallbacks[0] = %s with TLS %+v, want db-b with ServerName db-b", fb.Host, fb.TLSConfig)
+	}
+	if fb := cfg.Fallbacks[1]; fb.Host != "/var/run/postgresql" || fb.TLSConfig != nil {
+		t.Errorf("Fallbacks[1] = %s with TLS %+v, want the unix socket without TLS", fb.Host, fb.TLSConfig)
+	}
+
+	explicit, err := newConfig([]Option{WithTLSConfig(&tls.Config{ServerName: "db.example.com"})})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	cfg, err = explicit.pgxConfig("host=db-a,db-b user=app")
+	if err != nil {
+		t.Fatalf("pgxConfig() error = %v", err)
+	}
+	if cfg.TLSConfig.ServerName != "db.example.com" || cfg.Fallbacks[0].TLSConfig.ServerName != "db.example.com" {
+		t.Error("ServerName set by the caller was overridden")
+	}
+}